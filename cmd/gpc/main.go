@@ -0,0 +1,61 @@
+// Command gpc plays .http files outside of go test.
+//
+//	gpc run [flags] file.http|glob ...
+//
+// The flags of run are:
+//
+//	--env name          activate the environment from http-client.env.json files
+//	--var name=value    set the variable, can be repeated
+//	--unix-socket path  send all requests to the unix socket
+//	--junit file        write JUnit XML report to the file
+//	--json file         write JSON report to the file
+//	--html file         write HTML report to the file
+//	--ndjson file       stream events as newline delimited JSON to the file, - for stdout
+//
+// Console output of the scripts and the results of client.test are printed for each request.
+// The exit code is 1 when any request or test fails and 2 when the files can't be played.
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+)
+
+const (
+	exitFailed = 1
+	exitError  = 2
+)
+
+func usage(w io.Writer) {
+	fmt.Fprintln(w, "usage: gpc run [flags] file.http|glob ...")
+	fmt.Fprintln(w, "run 'gpc run -h' for the flags")
+}
+
+func main() {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	code := command(ctx, os.Args[1:], os.Stdout, os.Stderr)
+	stop()
+	os.Exit(code)
+}
+
+// command dispatches the sub-command and returns the exit code.
+func command(ctx context.Context, args []string, stdout, stderr io.Writer) int {
+	if len(args) == 0 {
+		usage(stderr)
+		return exitError
+	}
+	switch args[0] {
+	case "run":
+		return run(ctx, args[1:], stdout, stderr)
+	case "help", "-h", "--help":
+		usage(stdout)
+		return 0
+	default:
+		fmt.Fprintf(stderr, "gpc: unknown command %q\n", args[0])
+		usage(stderr)
+		return exitError
+	}
+}
@@ -0,0 +1,248 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/strotz/goplaycalls/gpc"
+	"github.com/strotz/goplaycalls/pipes"
+)
+
+// varsFlag collects repeated --var name=value flags.
+type varsFlag map[string]string
+
+func (v varsFlag) String() string {
+	var pairs []string
+	for k, val := range v {
+		pairs = append(pairs, k+"="+val)
+	}
+	return strings.Join(pairs, ",")
+}
+
+func (v varsFlag) Set(s string) error {
+	name, value, ok := strings.Cut(s, "=")
+	if !ok || strings.TrimSpace(name) == "" {
+		return fmt.Errorf("%q is not name=value", s)
+	}
+	v[strings.TrimSpace(name)] = value
+	return nil
+}
+
+type runFlags struct {
+	env        string
+	vars       varsFlag
+	unixSocket string
+	junit      string
+	json       string
+	ndjson     string
+	html       string
+}
+
+// run plays the files matching the patterns one after another.
+func run(ctx context.Context, args []string, stdout, stderr io.Writer) int {
+	flags := runFlags{vars: varsFlag{}}
+	fs := flag.NewFlagSet("gpc run", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	fs.StringVar(&flags.env, "env", "", "activate the `name`d environment from http-client.env.json files")
+	fs.Var(flags.vars, "var", "set the variable as `name=value`, can be repeated")
+	fs.StringVar(&flags.unixSocket, "unix-socket", "", "send all requests to the unix socket at `path`")
+	fs.StringVar(&flags.junit, "junit", "", "write JUnit XML report to the `file`")
+	fs.StringVar(&flags.json, "json", "", "write JSON report to the `file`")
+	fs.StringVar(&flags.html, "html", "", "write HTML report to the `file`")
+	fs.StringVar(&flags.ndjson, "ndjson", "", "stream events as newline delimited JSON to the `file`, - for stdout")
+	fs.Usage = func() {
+		fmt.Fprintln(stderr, "usage: gpc run [flags] file.http|glob ...")
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return 0
+		}
+		return exitError
+	}
+
+	files, err := expandPatterns(fs.Args())
+	if err != nil {
+		fmt.Fprintln(stderr, err)
+		return exitError
+	}
+	if len(files) == 0 {
+		fs.Usage()
+		return exitError
+	}
+
+	var events *gpc.NDJSONWriter
+	if flags.ndjson == "-" {
+		// Events are written to stdout, so the text goes to stderr.
+		events = gpc.NewNDJSONWriter(stdout)
+		stdout = stderr
+	} else if flags.ndjson != "" {
+		f, err := os.Create(flags.ndjson)
+		if err != nil {
+			fmt.Fprintln(stderr, err)
+			return exitError
+		}
+		defer f.Close()
+		events = gpc.NewNDJSONWriter(f)
+	}
+
+	code := 0
+	var reports []gpc.Report
+	for _, file := range files {
+		report, fileCode := playFile(ctx, file, flags, events, stdout, stderr)
+		if report != nil {
+			reports = append(reports, *report)
+		}
+		switch fileCode {
+		case exitError:
+			code = exitError
+		case exitFailed:
+			if code == 0 {
+				code = exitFailed
+			}
+		}
+		if ctx.Err() != nil {
+			break
+		}
+	}
+	if events != nil && events.Err() != nil {
+		fmt.Fprintf(stderr, "failed to write events: %v\n", events.Err())
+		code = exitError
+	}
+	if flags.junit != "" {
+		if err := writeReport(flags.junit, reports, gpc.WriteJUnit); err != nil {
+			fmt.Fprintln(stderr, err)
+			return exitError
+		}
+	}
+	if flags.json != "" {
+		if err := writeReport(flags.json, reports, gpc.WriteJSON); err != nil {
+			fmt.Fprintln(stderr, err)
+			return exitError
+		}
+	}
+	if flags.html != "" {
+		if err := writeReport(flags.html, reports, gpc.WriteHTML); err != nil {
+			fmt.Fprintln(stderr, err)
+			return exitError
+		}
+	}
+	return code
+}
+
+// writeReport writes the reports to the file with the writer.
+func writeReport(path string, reports []gpc.Report, write func(w io.Writer, reports ...gpc.Report) error) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if err := write(f, reports...); err != nil {
+		f.Close()
+		return fmt.Errorf("failed to write %s: %w", path, err)
+	}
+	return f.Close()
+}
+
+// expandPatterns returns the files matching the glob patterns. Patterns without
+// meta characters are used as is, so a missing file is reported when it is played.
+func expandPatterns(patterns []string) ([]string, error) {
+	var files []string
+	for _, pattern := range patterns {
+		matches, err := filepath.Glob(pattern)
+		if err != nil {
+			return nil, fmt.Errorf("invalid pattern %q: %w", pattern, err)
+		}
+		if len(matches) == 0 {
+			if !strings.ContainsAny(pattern, `*?[\`) {
+				files = append(files, pattern)
+				continue
+			}
+			return nil, fmt.Errorf("no files match %q", pattern)
+		}
+		files = append(files, matches...)
+	}
+	return files, nil
+}
+
+// playFile plays the file and prints every request with its console output and tests.
+// The report is nil when the file can't be played.
+func playFile(ctx context.Context, file string, flags runFlags, events *gpc.NDJSONWriter, stdout, stderr io.Writer) (*gpc.Report, int) {
+	p, err := gpc.ParseFile(file)
+	if err != nil {
+		fmt.Fprintln(stderr, err)
+		return nil, exitError
+	}
+	if flags.env != "" {
+		if err := p.UseEnvironment(flags.env); err != nil {
+			fmt.Fprintf(stderr, "%s: %v\n", file, err)
+			return nil, exitError
+		}
+	}
+	for k, v := range flags.vars {
+		p.SetVariable(k, v)
+	}
+	if flags.unixSocket != "" {
+		p.Dialer = pipes.CreateDialer(flags.unixSocket)
+	}
+	if events != nil {
+		p.Observer = events.Event
+	}
+
+	fmt.Fprintf(stdout, "=== %s\n", file)
+	report, err := p.PlayContext(ctx)
+	for _, step := range report.Steps() {
+		printStep(stdout, step)
+	}
+	if err != nil && ctx.Err() != nil {
+		fmt.Fprintf(stdout, "FAIL\t%s\t%v\n", file, err)
+		return &report, exitFailed
+	}
+	if report.TestFailed() {
+		fmt.Fprintf(stdout, "FAIL\t%s\n", file)
+		return &report, exitFailed
+	}
+	fmt.Fprintf(stdout, "ok\t%s\n", file)
+	return &report, 0
+}
+
+func printStep(w io.Writer, step gpc.StepResult) {
+	status := "PASS"
+	if step.Failed() {
+		status = "FAIL"
+	}
+	fmt.Fprintf(w, "--- %s: %s\n", status, step.Name())
+	printIndented(w, step.PreRequestHandlerOutput())
+	printIndented(w, step.ResponseHandlerOutput())
+	// The console has RUN, PASS and FAILED lines already, only locations of failures are added.
+	for _, result := range step.TestResults() {
+		if result.Passed {
+			continue
+		}
+		if loc := result.Location(); loc != "" {
+			fmt.Fprintf(w, "    %s: %s: %s\n", result.Name, loc, result.Message)
+		} else {
+			fmt.Fprintf(w, "    %s: %s\n", result.Name, result.Message)
+		}
+	}
+	var stepErr *gpc.StepError
+	if errors.As(step.Err(), &stepErr) && stepErr.Kind != gpc.AssertionFailure {
+		// Failed assertions are reported per test above.
+		fmt.Fprintf(w, "    %s: %v\n", stepErr.Kind, stepErr)
+	}
+}
+
+func printIndented(w io.Writer, text string) {
+	text = strings.TrimRight(text, "\n")
+	if text == "" {
+		return
+	}
+	for _, line := range strings.Split(text, "\n") {
+		fmt.Fprintf(w, "    %s\n", line)
+	}
+}
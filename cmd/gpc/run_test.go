@@ -0,0 +1,146 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/strotz/goplaycalls/testserver"
+)
+
+const passing = `### echo
+POST http://localhost/echo
+
+{{greeting}}
+
+> {%
+    console.log("got", response.body)
+    client.test("echoed", function() {
+        client.assert(response.body === "hello", "unexpected body")
+    })
+%}
+`
+
+const failing = `### echo
+POST http://localhost/echo
+
+bye
+
+> {%
+    client.test("echoed", function() {
+        client.assert(response.body === "hello", "unexpected body")
+    })
+%}
+`
+
+func writeFile(t *testing.T, dir, name, content string) string {
+	path := filepath.Join(dir, name)
+	require.NoError(t, os.WriteFile(path, []byte(content), 0644))
+	return path
+}
+
+func TestRun(t *testing.T) {
+	socket := t.Name()
+	ts := testserver.NewHandlerTestServer(socket, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = io.Copy(w, r.Body)
+	}))
+	ts.Start()
+	t.Cleanup(ts.Stop)
+
+	dir := t.TempDir()
+	pass := writeFile(t, dir, "a_pass.http", passing)
+	fail := writeFile(t, dir, "b_fail.http", failing)
+
+	t.Run("passing file", func(t *testing.T) {
+		var stdout, stderr bytes.Buffer
+		code := command(context.Background(), []string{"run", "--unix-socket", socket, "--var", "greeting=hello", pass}, &stdout, &stderr)
+		assert.Equal(t, 0, code, stderr.String())
+		assert.Contains(t, stdout.String(), "--- PASS: echo\n")
+		assert.Contains(t, stdout.String(), "    got hello\n")
+		assert.Contains(t, stdout.String(), "    PASS: echoed\n")
+		assert.Contains(t, stdout.String(), "ok\t"+pass+"\n")
+	})
+	t.Run("glob with failing file", func(t *testing.T) {
+		var stdout, stderr bytes.Buffer
+		code := command(context.Background(), []string{"run", "--unix-socket", socket, "--var", "greeting=hello", filepath.Join(dir, "*.http")}, &stdout, &stderr)
+		assert.Equal(t, exitFailed, code, stderr.String())
+		assert.Contains(t, stdout.String(), "ok\t"+pass+"\n")
+		assert.Contains(t, stdout.String(), "--- FAIL: echo\n")
+		assert.Contains(t, stdout.String(), "    echoed: "+fail+":8:22: unexpected body\n")
+		assert.Contains(t, stdout.String(), "FAIL\t"+fail+"\n")
+	})
+	t.Run("JUnit report", func(t *testing.T) {
+		junit := filepath.Join(dir, "junit.xml")
+		var stdout, stderr bytes.Buffer
+		code := command(context.Background(), []string{"run", "--unix-socket", socket, "--var", "greeting=hello", "--junit", junit, pass, fail}, &stdout, &stderr)
+		assert.Equal(t, exitFailed, code, stderr.String())
+		data, err := os.ReadFile(junit)
+		require.NoError(t, err)
+		assert.Contains(t, string(data), `<testsuites tests="2" failures="1" errors="0"`)
+		assert.Contains(t, string(data), `<testsuite name="`+fail+`"`)
+	})
+	t.Run("HTML report", func(t *testing.T) {
+		report := filepath.Join(dir, "report.html")
+		var stdout, stderr bytes.Buffer
+		code := command(context.Background(), []string{"run", "--unix-socket", socket, "--var", "greeting=hello", "--html", report, pass, fail}, &stdout, &stderr)
+		assert.Equal(t, exitFailed, code, stderr.String())
+		data, err := os.ReadFile(report)
+		require.NoError(t, err)
+		assert.Contains(t, string(data), "1 of 2 requests passed, 1 of 2 tests passed")
+	})
+	t.Run("JSON report and events", func(t *testing.T) {
+		report := filepath.Join(dir, "report.json")
+		var stdout, stderr bytes.Buffer
+		code := command(context.Background(), []string{"run", "--unix-socket", socket, "--var", "greeting=hello", "--json", report, "--ndjson", "-", pass}, &stdout, &stderr)
+		assert.Equal(t, 0, code, stderr.String())
+		assert.Contains(t, stderr.String(), "ok\t"+pass+"\n")
+		lines := strings.Split(strings.TrimSpace(stdout.String()), "\n")
+		require.Len(t, lines, 4)
+		assert.Contains(t, lines[0], `"type":"playStart"`)
+		assert.Contains(t, lines[3], `"type":"playFinish"`)
+		data, err := os.ReadFile(report)
+		require.NoError(t, err)
+		assert.Contains(t, string(data), `"schemaVersion": 1`)
+		assert.Contains(t, string(data), `"file": "`+pass+`"`)
+	})
+	t.Run("unresolved variable", func(t *testing.T) {
+		var stdout, stderr bytes.Buffer
+		code := command(context.Background(), []string{"run", "--unix-socket", socket, pass}, &stdout, &stderr)
+		assert.Equal(t, exitFailed, code)
+		assert.Contains(t, stdout.String(), "unresolved variables: greeting")
+	})
+}
+
+func TestRunErrors(t *testing.T) {
+	dir := t.TempDir()
+	broken := writeFile(t, dir, "broken.http", "get example.com\n")
+	tests := []struct {
+		name string
+		args []string
+		want string
+	}{
+		{"no command", nil, "usage: gpc run"},
+		{"unknown command", []string{"play"}, `unknown command "play"`},
+		{"no files", []string{"run"}, "usage: gpc run"},
+		{"bad variable", []string{"run", "--var", "novalue", broken}, `"novalue" is not name=value`},
+		{"no match", []string{"run", filepath.Join(dir, "*.rest")}, "no files match"},
+		{"parse error", []string{"run", broken}, broken + `:1:1: unexpected "get"`},
+		{"missing environment", []string{"run", "--env", "dev", writeFile(t, dir, "ok.http", "GET example.com\n")}, "dev"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var stdout, stderr bytes.Buffer
+			code := command(context.Background(), tt.args, &stdout, &stderr)
+			assert.Equal(t, exitError, code)
+			assert.Contains(t, stderr.String(), tt.want)
+		})
+	}
+}
@@ -0,0 +1,159 @@
+// Command gpcfmt formats .http files.
+//
+// Without paths it formats standard input. Directories are processed recursively.
+//
+//	gpcfmt [flags] [path ...]
+//
+// The flags are:
+//
+//	-d  display diffs instead of rewriting files
+//	-l  list files whose formatting differs from gpcfmt's
+//	-w  write result to (source) file instead of stdout
+package main
+
+import (
+	"bytes"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+
+	"github.com/pmezard/go-difflib/difflib"
+
+	"github.com/strotz/goplaycalls/gpc"
+)
+
+const httpExt = ".http"
+
+const exitError = 2
+
+type fmtFlags struct {
+	list   bool
+	write  bool
+	doDiff bool
+}
+
+func main() {
+	os.Exit(gpcfmt(os.Args[1:], os.Stdin, os.Stdout, os.Stderr))
+}
+
+// gpcfmt formats the files or stdin and returns the exit code.
+func gpcfmt(args []string, stdin io.Reader, stdout, stderr io.Writer) int {
+	var flags fmtFlags
+	fset := flag.NewFlagSet("gpcfmt", flag.ContinueOnError)
+	fset.SetOutput(stderr)
+	fset.BoolVar(&flags.list, "l", false, "list files whose formatting differs from gpcfmt's")
+	fset.BoolVar(&flags.write, "w", false, "write result to (source) file instead of stdout")
+	fset.BoolVar(&flags.doDiff, "d", false, "display diffs instead of rewriting files")
+	fset.Usage = func() {
+		fmt.Fprintf(stderr, "usage: gpcfmt [flags] [path ...]\n")
+		fset.PrintDefaults()
+	}
+	if err := fset.Parse(args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return 0
+		}
+		return exitError
+	}
+
+	if fset.NArg() == 0 {
+		if flags.write {
+			fmt.Fprintln(stderr, "error: cannot use -w with standard input")
+			return exitError
+		}
+		if err := processFile("<standard input>", stdin, stdout, flags); err != nil {
+			fmt.Fprintln(stderr, err)
+			return exitError
+		}
+		return 0
+	}
+
+	failed := false
+	for _, root := range fset.Args() {
+		err := filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+			// Files named explicitly are formatted whatever the extension is.
+			if d.IsDir() || (filepath.Ext(path) != httpExt && path != root) {
+				return nil
+			}
+			if err := processFile(path, nil, stdout, flags); err != nil {
+				fmt.Fprintln(stderr, err)
+				failed = true
+			}
+			return nil
+		})
+		if err != nil {
+			fmt.Fprintln(stderr, err)
+			failed = true
+		}
+	}
+	if failed {
+		return exitError
+	}
+	return 0
+}
+
+// processFile formats the file, in is used instead of the file content when not nil.
+func processFile(filename string, in io.Reader, out io.Writer, flags fmtFlags) error {
+	if in == nil {
+		f, err := os.Open(filename)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		in = f
+	}
+	src, err := io.ReadAll(in)
+	if err != nil {
+		return err
+	}
+	res, err := gpc.Format(src)
+	if err != nil {
+		var diags gpc.Diagnostics
+		if errors.As(err, &diags) {
+			return diags.WithFile(filename)
+		}
+		return fmt.Errorf("%s: %w", filename, err)
+	}
+	if bytes.Equal(src, res) {
+		if !flags.list && !flags.write && !flags.doDiff {
+			_, err = out.Write(res)
+		}
+		return err
+	}
+	if flags.list {
+		fmt.Fprintln(out, filename)
+	}
+	if flags.write {
+		info, err := os.Stat(filename)
+		if err != nil {
+			return err
+		}
+		if err := os.WriteFile(filename, res, info.Mode().Perm()); err != nil {
+			return err
+		}
+	}
+	if flags.doDiff {
+		diff, err := difflib.GetUnifiedDiffString(difflib.UnifiedDiff{
+			A:        difflib.SplitLines(string(src)),
+			B:        difflib.SplitLines(string(res)),
+			FromFile: filepath.ToSlash(filename + ".orig"),
+			ToFile:   filepath.ToSlash(filename),
+			Context:  3,
+		})
+		if err != nil {
+			return err
+		}
+		_, err = io.WriteString(out, diff)
+		return err
+	}
+	if !flags.list && !flags.write {
+		_, err = out.Write(res)
+	}
+	return err
+}
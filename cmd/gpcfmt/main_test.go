@@ -0,0 +1,116 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	unformatted = "GET example.com\naccept:*/*\n"
+	formatted   = "GET example.com\nAccept: */*\n"
+	invalid     = "get example.com\n"
+)
+
+func writeFile(t *testing.T, dir, name, content string) string {
+	path := filepath.Join(dir, name)
+	require.NoError(t, os.WriteFile(path, []byte(content), 0644))
+	return path
+}
+
+func readFile(t *testing.T, path string) string {
+	content, err := os.ReadFile(path)
+	require.NoError(t, err)
+	return string(content)
+}
+
+func TestGpcfmt(t *testing.T) {
+	setup := func(t *testing.T) (dir, bad, good, other string) {
+		dir = t.TempDir()
+		bad = writeFile(t, dir, "bad.http", unformatted)
+		good = writeFile(t, dir, "good.http", formatted)
+		other = writeFile(t, dir, "notes.txt", "accept:*/*\n")
+		return
+	}
+
+	t.Run("stdin", func(t *testing.T) {
+		var stdout, stderr bytes.Buffer
+		code := gpcfmt(nil, strings.NewReader("GET example.com\naccept:*/*"), &stdout, &stderr)
+		assert.Equal(t, 0, code, stderr.String())
+		assert.Equal(t, formatted, stdout.String())
+	})
+
+	t.Run("write stdin", func(t *testing.T) {
+		var stdout, stderr bytes.Buffer
+		code := gpcfmt([]string{"-w"}, strings.NewReader(formatted), &stdout, &stderr)
+		assert.Equal(t, exitError, code)
+		assert.Contains(t, stderr.String(), "cannot use -w with standard input")
+	})
+
+	t.Run("list", func(t *testing.T) {
+		dir, bad, good, other := setup(t)
+		var stdout, stderr bytes.Buffer
+		code := gpcfmt([]string{"-l", dir}, nil, &stdout, &stderr)
+		assert.Equal(t, 0, code, stderr.String())
+		assert.Equal(t, bad+"\n", stdout.String())
+		assert.Equal(t, unformatted, readFile(t, bad))
+		assert.Equal(t, formatted, readFile(t, good))
+		assert.Equal(t, "accept:*/*\n", readFile(t, other))
+	})
+
+	t.Run("write", func(t *testing.T) {
+		dir, bad, good, other := setup(t)
+		var stdout, stderr bytes.Buffer
+		code := gpcfmt([]string{"-w", dir}, nil, &stdout, &stderr)
+		assert.Equal(t, 0, code, stderr.String())
+		assert.Empty(t, stdout.String())
+		assert.Equal(t, formatted, readFile(t, bad))
+		assert.Equal(t, formatted, readFile(t, good))
+		assert.Equal(t, "accept:*/*\n", readFile(t, other))
+	})
+
+	t.Run("list and write", func(t *testing.T) {
+		dir, bad, _, _ := setup(t)
+		var stdout, stderr bytes.Buffer
+		code := gpcfmt([]string{"-l", "-w", dir}, nil, &stdout, &stderr)
+		assert.Equal(t, 0, code, stderr.String())
+		assert.Equal(t, bad+"\n", stdout.String())
+		assert.Equal(t, formatted, readFile(t, bad))
+	})
+
+	t.Run("diff", func(t *testing.T) {
+		dir, bad, _, _ := setup(t)
+		var stdout, stderr bytes.Buffer
+		code := gpcfmt([]string{"-d", dir}, nil, &stdout, &stderr)
+		assert.Equal(t, 0, code, stderr.String())
+		assert.True(t, strings.HasPrefix(stdout.String(), "--- "+filepath.ToSlash(bad)+".orig\n+++ "+filepath.ToSlash(bad)+"\n"), stdout.String())
+		assert.Contains(t, stdout.String(), " GET example.com\n-accept:*/*\n+Accept: */*\n")
+		assert.Equal(t, unformatted, readFile(t, bad))
+	})
+
+	t.Run("explicit file", func(t *testing.T) {
+		_, _, _, other := setup(t)
+		var stdout, stderr bytes.Buffer
+		code := gpcfmt([]string{"-l", other}, nil, &stdout, &stderr)
+		assert.Equal(t, exitError, code)
+		assert.Contains(t, stderr.String(), other)
+	})
+
+	t.Run("invalid file", func(t *testing.T) {
+		dir := t.TempDir()
+		broken := writeFile(t, dir, "broken.http", invalid)
+		good := writeFile(t, dir, "good.http", unformatted)
+		var stdout, stderr bytes.Buffer
+		code := gpcfmt([]string{"-w", dir}, nil, &stdout, &stderr)
+		assert.Equal(t, exitError, code)
+		assert.Contains(t, stderr.String(), broken+":1:1:")
+		assert.Equal(t, invalid, readFile(t, broken))
+		// Other files are formatted anyway.
+		assert.Equal(t, formatted, readFile(t, good))
+	})
+}
@@ -1,3 +1,4 @@
 #!/bin/sh
 
 goimports -l -w .
+go run ./cmd/gpcfmt -l -w .
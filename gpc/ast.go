@@ -0,0 +1,149 @@
+package gpc
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+)
+
+// Position is a location in a .http file. Line and Column are 1-based, Column counts runes.
+type Position struct {
+	Line   int
+	Column int
+}
+
+func (p Position) String() string {
+	return fmt.Sprintf("%d:%d", p.Line, p.Column)
+}
+
+// IsValid reports whether the position is known.
+func (p Position) IsValid() bool {
+	return p.Line > 0
+}
+
+func (p position) export() Position {
+	return Position{Line: p.line, Column: p.col}
+}
+
+// File is a parsed .http file, the same representation the Player executes.
+type File struct {
+	Name     string // Path of the file, empty when parsed from a reader.
+	Requests []*Request
+}
+
+// Request is a single request of the file, i.e. everything between two ### separators.
+type Request struct {
+	Name              string            // Text after the ### separator.
+	SeparatorPos      Position          // Position after ###, invalid when the request has no separator.
+	Comments          []Comment         // Comment lines of the request, including metadata ones.
+	Metadata          map[string]string // Values of # @key value comments, i.e. @name.
+	Method            string
+	URL               string
+	HTTPVersion       string // Text after the URL on the request line, i.e. HTTP/1.1, not used to play.
+	Headers           []Header
+	Body              string   // Inline body, variables are not expanded.
+	BodyFile          string   // Path of the < file body, relative to the file.
+	BodyPos           Position // Position of either body or body file.
+	PreRequestHandler *Script  // Script of < {% %} or < file.js before the request line.
+	ResponseHandler   *Script  // Script of > {% %} or > file.js after the request.
+	Pos               Position // Position of the request line.
+}
+
+// Header is a request header as written in the file.
+type Header struct {
+	Name  string
+	Value string
+	Pos   Position
+}
+
+// Comment is a # or // comment line. Text includes the comment start.
+type Comment struct {
+	Text string
+	Pos  Position
+}
+
+// Script is either an embedded script or a reference to a script file.
+type Script struct {
+	File    string   // Path of the script file, empty for embedded scripts.
+	Content string   // Source of the embedded script without {% and %}.
+	Pos     Position // Position of the embedded source or of the file path.
+}
+
+// IsEmbedded reports whether the script source is in the .http file.
+func (s *Script) IsEmbedded() bool {
+	return s.File == ""
+}
+
+// Parse reads a .http file from r. Parser problems are returned as Diagnostics.
+func Parse(r io.Reader) (*File, error) {
+	steps, err := makeRecipe(r)
+	if err != nil {
+		return nil, err
+	}
+	return newFile(steps), nil
+}
+
+// ParseAST reads the .http file at filePath. Diagnostics carry the file name.
+func ParseAST(filePath string) (*File, error) {
+	f, err := os.Open(filePath)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	file, err := Parse(bufio.NewReader(f))
+	var diags Diagnostics
+	if errors.As(err, &diags) {
+		return nil, diags.WithFile(filePath)
+	}
+	if err != nil {
+		return nil, err
+	}
+	file.Name = filePath
+	return file, nil
+}
+
+func newFile(steps []step) *File {
+	f := &File{Requests: make([]*Request, 0, len(steps))}
+	for _, s := range steps {
+		f.Requests = append(f.Requests, s.export())
+	}
+	return f
+}
+
+func (s step) export() *Request {
+	r := &Request{
+		Name:              s.name,
+		SeparatorPos:      s.separatorPos.export(),
+		Method:            s.method,
+		URL:               s.url,
+		HTTPVersion:       s.httpVersion,
+		Body:              s.body,
+		BodyFile:          s.bodyFile,
+		BodyPos:           s.bodyPos.export(),
+		PreRequestHandler: s.preRequestHandler.export(),
+		ResponseHandler:   s.responseHandler.export(),
+		Pos:               s.pos.export(),
+	}
+	if len(s.metadata) > 0 {
+		r.Metadata = make(map[string]string, len(s.metadata))
+		for k, v := range s.metadata {
+			r.Metadata[k] = v
+		}
+	}
+	for _, c := range s.comments {
+		r.Comments = append(r.Comments, Comment{Text: c.text, Pos: c.pos.export()})
+	}
+	for _, h := range s.headers {
+		r.Headers = append(r.Headers, Header{Name: h.name, Value: h.value, Pos: h.pos.export()})
+	}
+	return r
+}
+
+func (s *script) export() *Script {
+	if s == nil {
+		return nil
+	}
+	return &Script{File: s.file, Content: s.content, Pos: s.pos.export()}
+}
@@ -0,0 +1,86 @@
+package gpc
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParse(t *testing.T) {
+	f, err := Parse(strings.NewReader(`### create user
+# @name createUser
+< {% request.variables.set("id", "42") %}
+POST example.com/users/{{id}}
+Content-Type: application/json
+
+{"name": "joe"}
+
+> check.js
+
+###
+GET example.com/users
+
+< ./query.txt
+`))
+	require.NoError(t, err)
+	assert.Equal(t, &File{
+		Requests: []*Request{
+			{
+				Name:         "create user",
+				SeparatorPos: Position{Line: 1, Column: 4},
+				Comments: []Comment{
+					{Text: "# @name createUser", Pos: Position{Line: 2, Column: 1}},
+				},
+				Metadata: map[string]string{"name": "createUser"},
+				Method:   "POST",
+				URL:      "example.com/users/{{id}}",
+				Headers: []Header{
+					{Name: "Content-Type", Value: "application/json", Pos: Position{Line: 5, Column: 1}},
+				},
+				Body:    `{"name": "joe"}`,
+				BodyPos: Position{Line: 7, Column: 1},
+				PreRequestHandler: &Script{
+					Content: ` request.variables.set("id", "42") `,
+					Pos:     Position{Line: 3, Column: 5},
+				},
+				ResponseHandler: &Script{File: "check.js", Pos: Position{Line: 9, Column: 3}},
+				Pos:             Position{Line: 4, Column: 1},
+			},
+			{
+				Method:       "GET",
+				URL:          "example.com/users",
+				BodyFile:     "./query.txt",
+				BodyPos:      Position{Line: 14, Column: 1},
+				SeparatorPos: Position{Line: 11, Column: 4},
+				Pos:          Position{Line: 12, Column: 1},
+			},
+		},
+	}, f)
+	assert.True(t, f.Requests[0].PreRequestHandler.IsEmbedded())
+	assert.False(t, f.Requests[0].ResponseHandler.IsEmbedded())
+}
+
+func TestParseAST(t *testing.T) {
+	dir := t.TempDir()
+	t.Run("valid file", func(t *testing.T) {
+		recipe := filepath.Join(dir, "valid.http")
+		require.NoError(t, os.WriteFile(recipe, []byte("GET example.com\n"), 0644))
+		f, err := ParseAST(recipe)
+		require.NoError(t, err)
+		assert.Equal(t, recipe, f.Name)
+		require.Len(t, f.Requests, 1)
+		assert.Equal(t, "1:1", f.Requests[0].Pos.String())
+	})
+	t.Run("diagnostics", func(t *testing.T) {
+		recipe := filepath.Join(dir, "broken.http")
+		require.NoError(t, os.WriteFile(recipe, []byte("get example.com\n"), 0644))
+		_, err := ParseAST(recipe)
+		var diags Diagnostics
+		require.ErrorAs(t, err, &diags)
+		assert.Equal(t, recipe, diags[0].File)
+	})
+}
@@ -22,24 +22,8 @@ const Client = {
     exit() {
         // Exit VM
     },
-    runTests(response){
-        const failures = [];
-        if (this._tests) {
-            for (const t of this._tests) {
-                this.log(`RUN: ${t.testName}`);
-                try {
-                    t.func(response);
-                    this.log(`PASS: ${t.testName}`);
-                } catch (e) {
-                    this.log(`FAILED: ${t.testName}`);
-                    this.log(e.toString());
-                    failures.push(e);
-                }
-            }
-        }
-        return failures;
-    },
-    // TODO: globals kept across all sessions/VMs
+    // Variables kept across all steps, set by the player.
+    global: null,
 };
 
 const client = Object.create(Client);
\ No newline at end of file
@@ -0,0 +1,63 @@
+package gpc
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Diagnostic is a problem found in .http file.
+type Diagnostic struct {
+	File    string // Empty when the recipe is not read from the file.
+	Line    int
+	Column  int
+	Message string
+}
+
+func (d Diagnostic) String() string {
+	if d.File == "" {
+		return fmt.Sprintf("%d:%d: %s", d.Line, d.Column, d.Message)
+	}
+	return fmt.Sprintf("%s:%d:%d: %s", d.File, d.Line, d.Column, d.Message)
+}
+
+// Diagnostics lists all problems found in .http file, it is returned as an error by the parser.
+type Diagnostics []Diagnostic
+
+func (d Diagnostics) Error() string {
+	lines := make([]string, 0, len(d))
+	for _, diag := range d {
+		lines = append(lines, diag.String())
+	}
+	return strings.Join(lines, "\n")
+}
+
+// add records the problem at the position of the item.
+func (d *Diagnostics) add(it item, format string, args ...any) {
+	*d = append(*d, Diagnostic{
+		Line:    it.pos.line,
+		Column:  it.pos.col,
+		Message: fmt.Sprintf(format, args...),
+	})
+}
+
+// WithFile sets the file name of all diagnostics.
+func (d Diagnostics) WithFile(file string) Diagnostics {
+	for i := range d {
+		d[i].File = file
+	}
+	return d
+}
+
+// errorMessage describes the problem reported by the scanner with tokenError.
+func errorMessage(val string) string {
+	switch {
+	case val == "":
+		return "unexpected end of file"
+	case val == bodyFileStart:
+		return "body file path is missing"
+	case strings.HasPrefix(val, scriptStart):
+		return "unterminated script, " + scriptEnd + " is missing"
+	default:
+		return fmt.Sprintf("unexpected %q", val)
+	}
+}
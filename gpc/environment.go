@@ -0,0 +1,88 @@
+package gpc
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// Environment files are looked up next to the .http file, values from the private file override public ones.
+const (
+	publicEnvFile  = "http-client.env.json"
+	privateEnvFile = "http-client.private.env.json"
+)
+
+// sharedEnv is the environment with values available in every other environment.
+const sharedEnv = "$shared"
+
+// loadEnvironment reads variables of the named environment from the environment files in dir.
+// Values of $shared come first, so any value of the named environment overrides them. Within each
+// of them the private file overrides the public one.
+func loadEnvironment(dir, name string) (map[string]string, error) {
+	var files []map[string]map[string]string
+	for _, file := range []string{publicEnvFile, privateEnvFile} {
+		envs, err := readEnvFile(filepath.Join(dir, file))
+		if err != nil {
+			return nil, err
+		}
+		files = append(files, envs)
+	}
+	res := make(map[string]string)
+	found := false
+	for _, n := range []string{sharedEnv, name} {
+		for _, envs := range files {
+			env, ok := envs[n]
+			if !ok {
+				continue
+			}
+			found = found || n == name
+			for k, v := range env {
+				res[k] = v
+			}
+		}
+	}
+	if !found {
+		return nil, fmt.Errorf("environment %q is not defined in %s or %s", name, publicEnvFile, privateEnvFile)
+	}
+	return res, nil
+}
+
+// readEnvFile returns environments declared in the file. Missing file has no environments.
+func readEnvFile(path string) (map[string]map[string]string, error) {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	d := json.NewDecoder(bytes.NewReader(data))
+	d.UseNumber()
+	var raw map[string]map[string]any
+	if err := d.Decode(&raw); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", path, err)
+	}
+	res := make(map[string]map[string]string, len(raw))
+	for name, vars := range raw {
+		env := make(map[string]string, len(vars))
+		for k, v := range vars {
+			switch val := v.(type) {
+			case string:
+				env[k] = val
+			case json.Number, bool:
+				env[k] = fmt.Sprint(val)
+			default:
+				b, err := json.Marshal(val)
+				if err != nil {
+					return nil, err
+				}
+				env[k] = string(b)
+			}
+		}
+		res[name] = env
+	}
+	return res, nil
+}
@@ -0,0 +1,85 @@
+package gpc
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLoadEnvironment(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, publicEnvFile), []byte(`{
+  "$shared": {"version": "v1"},
+  "local": {"host": "http://localhost:8080", "token": "public", "port": 8080},
+  "ci": {"host": "http://ci"}
+}`), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, privateEnvFile), []byte(`{
+  "local": {"token": "private"},
+  "staging-stub": {"token": "stub"}
+}`), 0644))
+
+	t.Run("private over public", func(t *testing.T) {
+		env, err := loadEnvironment(dir, "local")
+		require.NoError(t, err)
+		assert.Equal(t, map[string]string{
+			"version": "v1",
+			"host":    "http://localhost:8080",
+			"token":   "private",
+			"port":    "8080",
+		}, env)
+	})
+
+	t.Run("only private", func(t *testing.T) {
+		env, err := loadEnvironment(dir, "staging-stub")
+		require.NoError(t, err)
+		assert.Equal(t, map[string]string{
+			"version": "v1",
+			"token":   "stub",
+		}, env)
+	})
+
+	t.Run("environment over private shared", func(t *testing.T) {
+		dir := t.TempDir()
+		require.NoError(t, os.WriteFile(filepath.Join(dir, publicEnvFile), []byte(`{
+  "$shared": {"host": "http://shared", "version": "v1"},
+  "local": {"host": "http://localhost"}
+}`), 0644))
+		require.NoError(t, os.WriteFile(filepath.Join(dir, privateEnvFile), []byte(`{
+  "$shared": {"host": "http://private-shared", "version": "v2"}
+}`), 0644))
+		env, err := loadEnvironment(dir, "local")
+		require.NoError(t, err)
+		assert.Equal(t, map[string]string{
+			"host":    "http://localhost",
+			"version": "v2",
+		}, env)
+	})
+
+	t.Run("missing environment", func(t *testing.T) {
+		_, err := loadEnvironment(dir, "prod")
+		assert.ErrorContains(t, err, `environment "prod" is not defined`)
+	})
+
+	t.Run("no files", func(t *testing.T) {
+		_, err := loadEnvironment(t.TempDir(), "local")
+		assert.Error(t, err)
+	})
+
+	t.Run("player uses environment", func(t *testing.T) {
+		recipe := filepath.Join(dir, "env.http")
+		require.NoError(t, os.WriteFile(recipe, []byte(`GET {{host}}/{{version}}`), 0644))
+		p, err := ParseFile(recipe)
+		require.NoError(t, err)
+		require.NoError(t, p.UseEnvironment("ci"))
+		v, ok := p.lookup("host")
+		assert.True(t, ok)
+		assert.Equal(t, "http://ci", v)
+
+		p.SetVariable("host", "http://override")
+		v, _ = p.lookup("host")
+		assert.Equal(t, "http://override", v)
+	})
+}
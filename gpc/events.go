@@ -0,0 +1,70 @@
+package gpc
+
+import (
+	"encoding/json"
+	"io"
+	"sync"
+	"time"
+)
+
+// EventType tells what happened during the play.
+type EventType string
+
+const (
+	EventPlayStart  EventType = "playStart"  // The file is about to be played.
+	EventStepStart  EventType = "stepStart"  // The request is about to be played, Step has name, method and URL as written in the file.
+	EventStepFinish EventType = "stepFinish" // The request is played, Step has the result.
+	EventPlayFinish EventType = "playFinish" // All requests are played, Failed tells the outcome.
+)
+
+// Event is passed to Player.Observer while the file is played.
+type Event struct {
+	Type   EventType `json:"type"`
+	Time   time.Time `json:"time"`
+	File   string    `json:"file,omitempty"`
+	Step   *JSONStep `json:"step,omitempty"`
+	Failed *bool     `json:"failed,omitempty"` // Set for EventPlayFinish only.
+}
+
+// emit passes the event to the observer, if any.
+func (p *Player) emit(typ EventType, step *JSONStep) {
+	if p.Observer == nil {
+		return
+	}
+	e := Event{Type: typ, Time: time.Now(), File: p.fileName, Step: step}
+	if typ == EventPlayFinish {
+		failed := p.report.TestFailed()
+		e.Failed = &failed
+	}
+	p.Observer(e)
+}
+
+// NDJSONWriter writes events as newline delimited JSON, one event per line.
+// Use its Event method as Player.Observer.
+type NDJSONWriter struct {
+	mu  sync.Mutex
+	enc *json.Encoder
+	err error
+}
+
+// NewNDJSONWriter creates the writer of events to w.
+func NewNDJSONWriter(w io.Writer) *NDJSONWriter {
+	return &NDJSONWriter{enc: json.NewEncoder(w)}
+}
+
+// Event writes the event. After the first failure events are dropped, see Err.
+func (n *NDJSONWriter) Event(e Event) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	if n.err != nil {
+		return
+	}
+	n.err = n.enc.Encode(e)
+}
+
+// Err returns the first error of the writer.
+func (n *NDJSONWriter) Err() error {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	return n.err
+}
@@ -0,0 +1,47 @@
+package gpc
+
+// globalStore backs client.global in scripts. It is owned by Player, so values survive between steps and
+// can be used in {{name}} placeholders of the following requests.
+type globalStore struct {
+	values map[string]string
+}
+
+func newGlobalStore() *globalStore {
+	return &globalStore{
+		values: make(map[string]string),
+	}
+}
+
+// Set is client.global.set(name, value)
+func (g *globalStore) Set(name string, value string) {
+	g.values[name] = value
+}
+
+// Get is client.global.get(name), returns null when the variable is not set.
+func (g *globalStore) Get(name string) any {
+	v, ok := g.values[name]
+	if !ok {
+		return nil
+	}
+	return v
+}
+
+// IsEmpty is client.global.isEmpty()
+func (g *globalStore) IsEmpty() bool {
+	return len(g.values) == 0
+}
+
+// Clear is client.global.clear(name)
+func (g *globalStore) Clear(name string) {
+	delete(g.values, name)
+}
+
+// ClearAll is client.global.clearAll()
+func (g *globalStore) ClearAll() {
+	clear(g.values)
+}
+
+func (g *globalStore) lookup(name string) (string, bool) {
+	v, ok := g.values[name]
+	return v, ok
+}
@@ -0,0 +1,48 @@
+package gpc
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+)
+
+// handlerTransport is http.RoundTripper that dispatches requests directly to the handler, without any listener.
+type handlerTransport struct {
+	handler http.Handler
+}
+
+func (h handlerTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	// Make the request look like the one received by the server.
+	r := req.Clone(req.Context())
+	r.RequestURI = req.URL.RequestURI()
+	r.RemoteAddr = "192.0.2.1:1234"
+	if r.Host == "" {
+		r.Host = req.URL.Host
+	}
+	if r.Body == nil {
+		r.Body = http.NoBody
+	}
+	defer r.Body.Close()
+
+	w := httptest.NewRecorder()
+	done := make(chan any, 1)
+	go func() {
+		// Like net/http server, the panic fails only the request and not the whole process.
+		defer func() {
+			done <- recover()
+		}()
+		h.handler.ServeHTTP(w, r)
+	}()
+	select {
+	case p := <-done:
+		if p != nil {
+			return nil, fmt.Errorf("handler panic: %v", p)
+		}
+	case <-req.Context().Done():
+		// Handler is abandoned, the recorder is not used anymore.
+		return nil, req.Context().Err()
+	}
+	res := w.Result()
+	res.Request = req
+	return res, nil
+}
@@ -0,0 +1,95 @@
+package gpc
+
+import (
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHandlerTransport(t *testing.T) {
+	sm := http.NewServeMux()
+	sm.HandleFunc("POST /items/{id}", func(response http.ResponseWriter, req *http.Request) {
+		body, _ := io.ReadAll(req.Body)
+		response.Header().Set("Content-Type", "application/json")
+		response.WriteHeader(http.StatusCreated)
+		_, _ = io.WriteString(response, `{"id": "`+req.PathValue("id")+`", "body": "`+string(body)+`", "uri": "`+req.RequestURI+`"}`)
+	})
+
+	p, err := ParseString(`### Create item
+POST http://in-process/items/7?x=1
+
+payload
+
+> {%
+client.test("created", function() {
+	client.assert(response.status === 201, "unexpected status");
+	client.assert(response.body.id === "7", "unexpected id");
+	client.assert(response.body.body === "payload", "unexpected body");
+	client.assert(response.body.uri === "/items/7?x=1", "unexpected uri");
+});
+%}
+
+### Missing
+GET http://in-process/missing
+
+> {%
+client.test("not found", function() {
+	client.assert(response.status === 404, "unexpected status");
+});
+%}
+`)
+	require.NoError(t, err)
+	p.Handler = sm
+	r, err := p.Play()
+	require.NoError(t, err)
+	require.Len(t, r.Steps(), 2)
+	for _, s := range r.Steps() {
+		assert.False(t, s.Failed(), s.ResponseHandlerOutput())
+	}
+}
+
+func TestHandlerTransportPanic(t *testing.T) {
+	sm := http.NewServeMux()
+	sm.HandleFunc("GET /boom", func(http.ResponseWriter, *http.Request) {
+		panic("boom")
+	})
+	sm.HandleFunc("GET /ok", func(http.ResponseWriter, *http.Request) {})
+
+	p, err := ParseString(`### Boom
+GET http://in-process/boom
+
+### Ok
+GET http://in-process/ok
+`)
+	require.NoError(t, err)
+	p.Handler = sm
+	r, err := p.Play()
+	require.Error(t, err)
+	require.Len(t, r.Steps(), 1)
+	var stepErr *StepError
+	require.ErrorAs(t, r.Steps()[0].Err(), &stepErr)
+	assert.Equal(t, TransportError, stepErr.Kind)
+	assert.ErrorContains(t, stepErr, "handler panic: boom")
+
+	// The transport keeps serving after the panic.
+	p.ContinueOnError = true
+	r, err = p.Play()
+	require.NoError(t, err)
+	require.Len(t, r.Steps(), 2)
+	assert.NoError(t, r.Steps()[1].Err())
+}
+
+func TestRunWithHandler(t *testing.T) {
+	sm := http.NewServeMux()
+	sm.HandleFunc("GET /a", echoHandler)
+	recipe := filepath.Join(t.TempDir(), "handler.http")
+	require.NoError(t, os.WriteFile(recipe, []byte(`GET http://in-process/a`), 0644))
+	r := Run(t, recipe, WithHandler(sm))
+	require.Len(t, r.Steps(), 1)
+	assert.Equal(t, http.StatusOK, r.Steps()[0].res.StatusCode)
+}
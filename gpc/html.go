@@ -0,0 +1,189 @@
+package gpc
+
+import (
+	"bytes"
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"html/template"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+//go:embed report.html
+var htmlSource string
+
+var htmlTemplate = template.Must(template.New("report").Funcs(template.FuncMap{
+	"badge": func(failed bool) template.HTML {
+		if failed {
+			return `<span class="badge fail">FAIL</span>`
+		}
+		return `<span class="badge pass">PASS</span>`
+	},
+}).Parse(htmlSource))
+
+type htmlReport struct {
+	Title          string
+	Files          []htmlFile
+	Requests       int
+	RequestsPassed int
+	Tests          int
+	TestsPassed    int
+	Duration       time.Duration
+}
+
+type htmlFile struct {
+	File     string
+	Started  string
+	Duration time.Duration
+	Failed   bool
+	Steps    []htmlStep
+}
+
+type htmlStep struct {
+	Name     string
+	Method   string
+	URL      string
+	Status   string
+	Duration time.Duration
+	Failed   bool
+	Error    string
+	Tests    []htmlTest
+	Request  *htmlMessage
+	Response *htmlMessage
+	Console  string
+}
+
+type htmlTest struct {
+	Name     string
+	Passed   bool
+	Message  string
+	Location string
+	Duration time.Duration
+}
+
+type htmlMessage struct {
+	Headers []htmlHeader
+	Body    string
+}
+
+type htmlHeader struct {
+	Name  string
+	Value string
+}
+
+// WriteHTML writes the reports as a self-contained HTML page. Each request is listed with its
+// tests, console output of the scripts, and collapsible request and response as they were sent
+// and received. JSON bodies are indented.
+func WriteHTML(w io.Writer, reports ...Report) error {
+	page := htmlReport{Title: "Play report"}
+	for _, r := range reports {
+		f := htmlFile{File: r.file, Failed: r.TestFailed()}
+		if !r.started.IsZero() {
+			f.Started = r.started.Format(time.RFC3339)
+		}
+		for _, s := range r.steps {
+			step := s.html()
+			f.Steps = append(f.Steps, step)
+			f.Duration += s.duration
+			page.Requests++
+			if !step.Failed {
+				page.RequestsPassed++
+			}
+			for _, t := range step.Tests {
+				page.Tests++
+				if t.Passed {
+					page.TestsPassed++
+				}
+			}
+		}
+		f.Duration = f.Duration.Round(time.Microsecond)
+		page.Duration += f.Duration
+		page.Files = append(page.Files, f)
+	}
+	if len(reports) == 1 && reports[0].file != "" {
+		page.Title = "Play report: " + reports[0].file
+	}
+	return htmlTemplate.Execute(w, page)
+}
+
+func (e StepResult) html() htmlStep {
+	js := e.JSON()
+	step := htmlStep{
+		Name:     js.Name,
+		Method:   js.Method,
+		URL:      js.URL,
+		Duration: e.duration.Round(time.Microsecond),
+		Failed:   e.Failed(),
+	}
+	for _, t := range e.TestResults() {
+		step.Tests = append(step.Tests, htmlTest{
+			Name:     t.Name,
+			Passed:   t.Passed,
+			Message:  t.Message,
+			Location: t.Location(),
+			Duration: t.Duration.Round(time.Microsecond),
+		})
+	}
+	if js.Error != nil {
+		step.Error = e.err.Kind.String() + ": " + js.Error.Message
+	}
+	if e.req != nil {
+		body := e.reqBody
+		if body == "" && e.step.bodyFile != "" {
+			body = bodyFileStart + " " + e.step.bodyFile
+		}
+		step.Request = &htmlMessage{
+			Headers: sortedHeaders(e.req.Header),
+			Body:    prettyBody(e.req.Header.Get("Content-Type"), []byte(body)),
+		}
+	}
+	if e.res != nil {
+		step.Status = e.res.Status
+		step.Response = &htmlMessage{
+			Headers: sortedHeaders(e.res.Header),
+			Body:    prettyBody(e.res.Header.Get("Content-Type"), e.resBody),
+		}
+	}
+	var console []string
+	for _, out := range []string{e.PreRequestHandlerOutput(), e.ResponseHandlerOutput()} {
+		if out != "" {
+			console = append(console, strings.TrimRight(out, "\n"))
+		}
+	}
+	step.Console = strings.Join(console, "\n")
+	return step
+}
+
+func sortedHeaders(h http.Header) []htmlHeader {
+	names := make([]string, 0, len(h))
+	for name := range h {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var res []htmlHeader
+	for _, name := range names {
+		for _, v := range h[name] {
+			res = append(res, htmlHeader{Name: name, Value: v})
+		}
+	}
+	return res
+}
+
+// prettyBody indents JSON bodies, binary bodies are replaced by their size.
+func prettyBody(contentType string, body []byte) string {
+	if !utf8.Valid(body) {
+		return fmt.Sprintf("(%d bytes of binary data)", len(body))
+	}
+	if parseContentType(contentType).isJSON() {
+		var out bytes.Buffer
+		if err := json.Indent(&out, body, "", "  "); err == nil {
+			return out.String()
+		}
+	}
+	return string(body)
+}
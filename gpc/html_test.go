@@ -0,0 +1,59 @@
+package gpc
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWriteHTML(t *testing.T) {
+	p, err := ParseString(`### create <user>
+POST http://localhost/users
+Content-Type: application/json
+
+{"name":"joe","tags":["a"]}
+
+> {%
+    console.log("created", response.body.name)
+    client.test("name is echoed", function() {
+        client.assert(response.body.name === "bob", "unexpected name")
+    })
+%}
+
+### unresolved
+GET http://localhost/{{missing}}
+`)
+	require.NoError(t, err)
+	p.Handler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		echoHandler(w, r)
+	})
+	p.ContinueOnError = true
+	report, err := p.Play()
+	require.NoError(t, err)
+
+	var sb strings.Builder
+	require.NoError(t, WriteHTML(&sb, report))
+	html := sb.String()
+	assert.True(t, strings.HasPrefix(html, "<!DOCTYPE html>"))
+	assert.Contains(t, html, "0 of 2 requests passed, 0 of 1 tests passed")
+	assert.Contains(t, html, "create &lt;user&gt;", "names are escaped")
+	assert.Contains(t, html, `<span class="method">POST</span> http://localhost/users &rarr; 200 OK`)
+	assert.Contains(t, html, "<td>Content-Type:</td><td>application/json</td>")
+	assert.Contains(t, html, "{\n  &#34;name&#34;: &#34;joe&#34;,\n  &#34;tags&#34;: [\n    &#34;a&#34;\n  ]\n}", "JSON body is indented")
+	assert.Contains(t, html, "created joe")
+	assert.Contains(t, html, `<span class="badge fail">FAIL</span> name is echoed`)
+	assert.Contains(t, html, "unexpected name")
+	assert.Contains(t, html, "request error: unresolved variables: missing")
+	assert.NotContains(t, html, "<script", "the report has no scripts")
+}
+
+func TestPrettyBody(t *testing.T) {
+	assert.Equal(t, "{\n  \"a\": 1\n}", prettyBody("application/json; charset=utf-8", []byte(`{"a":1}`)))
+	assert.Equal(t, `{"a":1}`, prettyBody("text/plain", []byte(`{"a":1}`)))
+	assert.Equal(t, "not json", prettyBody("application/json", []byte("not json")))
+	assert.Equal(t, "(2 bytes of binary data)", prettyBody("image/png", []byte{0xff, 0xfe}))
+}
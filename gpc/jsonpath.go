@@ -0,0 +1,91 @@
+package gpc
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// evalJSONPath evaluates simple JSONPath expression: $ followed by .key, ['key'] or [index] selectors.
+func evalJSONPath(path string, doc any) (any, error) {
+	rest, ok := strings.CutPrefix(strings.TrimSpace(path), "$")
+	if !ok {
+		return nil, fmt.Errorf("json path %q has to start with $", path)
+	}
+	current := doc
+	for rest != "" {
+		var key string
+		index := -1
+		switch {
+		case strings.HasPrefix(rest, "."):
+			rest = rest[1:]
+			end := strings.IndexAny(rest, ".[")
+			if end < 0 {
+				end = len(rest)
+			}
+			key, rest = rest[:end], rest[end:]
+		case strings.HasPrefix(rest, "["):
+			end := strings.Index(rest, "]")
+			if end < 0 {
+				return nil, fmt.Errorf("json path %q: missing ]", path)
+			}
+			selector := rest[1:end]
+			rest = rest[end+1:]
+			if unquoted, ok := unquoteSelector(selector); ok {
+				key = unquoted
+			} else {
+				i, err := strconv.Atoi(selector)
+				if err != nil {
+					return nil, fmt.Errorf("json path %q: invalid selector %s", path, selector)
+				}
+				index = i
+			}
+		default:
+			return nil, fmt.Errorf("json path %q: unexpected %q", path, rest)
+		}
+
+		if index >= 0 {
+			arr, ok := current.([]any)
+			if !ok || index >= len(arr) {
+				return nil, fmt.Errorf("json path %q: no element %d", path, index)
+			}
+			current = arr[index]
+			continue
+		}
+		obj, ok := current.(map[string]any)
+		if !ok {
+			return nil, fmt.Errorf("json path %q: no field %s", path, key)
+		}
+		current, ok = obj[key]
+		if !ok {
+			return nil, fmt.Errorf("json path %q: no field %s", path, key)
+		}
+	}
+	return current, nil
+}
+
+func unquoteSelector(s string) (string, bool) {
+	if len(s) >= 2 && (s[0] == '\'' || s[0] == '"') && s[len(s)-1] == s[0] {
+		return s[1 : len(s)-1], true
+	}
+	return "", false
+}
+
+// jsonValueString converts value selected from JSON document to the text used in substitutions.
+func jsonValueString(v any) (string, error) {
+	switch val := v.(type) {
+	case string:
+		return val, nil
+	case json.Number:
+		return val.String(), nil
+	case nil:
+		return "null", nil
+	default:
+		b, err := json.Marshal(val)
+		if err != nil {
+			return "", err
+		}
+		return string(b), nil
+	}
+}
@@ -0,0 +1,45 @@
+package gpc
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEvalJSONPath(t *testing.T) {
+	d := json.NewDecoder(strings.NewReader(`{"id": 42, "user": {"name": "Hello", "tags": ["a", "b"]}, "with.dot": true}`))
+	d.UseNumber()
+	var doc any
+	require.NoError(t, d.Decode(&doc))
+
+	tests := []struct {
+		path     string
+		expected string
+	}{
+		{"$.id", "42"},
+		{"$.user.name", "Hello"},
+		{"$.user.tags[1]", "b"},
+		{"$['user']['tags'][0]", "a"},
+		{`$["with.dot"]`, "true"},
+		{"$.user.tags", `["a","b"]`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			v, err := evalJSONPath(tt.path, doc)
+			require.NoError(t, err)
+			s, err := jsonValueString(v)
+			require.NoError(t, err)
+			assert.Equal(t, tt.expected, s)
+		})
+	}
+
+	for _, path := range []string{"id", "$.missing", "$.user.tags[5]", "$.user[", "$.id.name"} {
+		t.Run("invalid "+path, func(t *testing.T) {
+			_, err := evalJSONPath(path, doc)
+			assert.Error(t, err)
+		})
+	}
+}
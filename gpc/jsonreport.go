@@ -0,0 +1,124 @@
+package gpc
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"time"
+)
+
+// JSONSchemaVersion is incremented when fields of the JSON report are renamed or removed.
+const JSONSchemaVersion = 1
+
+// JSONReports is the document written by WriteJSON.
+type JSONReports struct {
+	SchemaVersion int          `json:"schemaVersion"`
+	Files         []JSONReport `json:"files"`
+}
+
+// JSONReport is the result of a played .http file.
+type JSONReport struct {
+	File       string     `json:"file"`
+	Started    time.Time  `json:"started"`
+	DurationMs float64    `json:"durationMs"`
+	Failed     bool       `json:"failed"`
+	Steps      []JSONStep `json:"steps"`
+}
+
+// JSONStep is the result of a played request. Method, URL and headers are the ones sent, after
+// variables are expanded, unless the request could not be created. In EventStepStart, which is emitted
+// before pre-request handler sets its variables, they are as written in the file.
+type JSONStep struct {
+	Name                  string      `json:"name"`
+	Method                string      `json:"method"`
+	URL                   string      `json:"url"`
+	Status                int         `json:"status,omitempty"`
+	RequestHeaders        http.Header `json:"requestHeaders,omitempty"`
+	ResponseHeaders       http.Header `json:"responseHeaders,omitempty"`
+	DurationMs            float64     `json:"durationMs"`
+	PreRequestOutput      string      `json:"preRequestOutput,omitempty"`
+	ResponseHandlerOutput string      `json:"responseHandlerOutput,omitempty"`
+	Tests                 []JSONTest  `json:"tests,omitempty"`
+	Error                 *JSONError  `json:"error,omitempty"`
+}
+
+// JSONTest is the result of a client.test.
+type JSONTest struct {
+	Name       string  `json:"name"`
+	Passed     bool    `json:"passed"`
+	Message    string  `json:"message,omitempty"`
+	Location   string  `json:"location,omitempty"`
+	DurationMs float64 `json:"durationMs"`
+}
+
+// JSONError is the failure of the step.
+type JSONError struct {
+	Kind    string `json:"kind"` // One of request, transport, script or assertion, see StepErrorKind.
+	Message string `json:"message"`
+}
+
+// WriteJSON writes the reports as an indented JSONReports document.
+func WriteJSON(w io.Writer, reports ...Report) error {
+	doc := JSONReports{SchemaVersion: JSONSchemaVersion, Files: make([]JSONReport, 0, len(reports))}
+	for _, r := range reports {
+		doc.Files = append(doc.Files, r.JSON())
+	}
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(doc)
+}
+
+// JSON returns the JSON representation of the report.
+func (r Report) JSON() JSONReport {
+	res := JSONReport{
+		File:    r.file,
+		Started: r.started,
+		Failed:  r.TestFailed(),
+		Steps:   make([]JSONStep, 0, len(r.steps)),
+	}
+	var total time.Duration
+	for _, s := range r.steps {
+		total += s.duration
+		res.Steps = append(res.Steps, s.JSON())
+	}
+	res.DurationMs = milliseconds(total)
+	return res
+}
+
+// JSON returns the JSON representation of the played step.
+func (e StepResult) JSON() JSONStep {
+	res := JSONStep{
+		Name:                  e.Name(),
+		Method:                e.step.method,
+		URL:                   e.step.url,
+		DurationMs:            milliseconds(e.duration),
+		PreRequestOutput:      e.PreRequestHandlerOutput(),
+		ResponseHandlerOutput: e.ResponseHandlerOutput(),
+	}
+	if e.req != nil {
+		res.Method = e.req.Method
+		res.URL = e.req.URL.String()
+		res.RequestHeaders = e.req.Header
+	}
+	if e.res != nil {
+		res.Status = e.res.StatusCode
+		res.ResponseHeaders = e.res.Header
+	}
+	for _, t := range e.TestResults() {
+		res.Tests = append(res.Tests, JSONTest{
+			Name:       t.Name,
+			Passed:     t.Passed,
+			Message:    t.Message,
+			Location:   t.Location(),
+			DurationMs: milliseconds(t.Duration),
+		})
+	}
+	if e.err != nil {
+		res.Error = &JSONError{Kind: e.err.Kind.id(), Message: e.err.Error()}
+	}
+	return res
+}
+
+func milliseconds(d time.Duration) float64 {
+	return float64(d) / float64(time.Millisecond)
+}
@@ -0,0 +1,129 @@
+package gpc
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const twoSteps = `### echo
+POST http://{{host}}/echo
+Content-Type: text/plain
+
+{{word}}
+
+> {%
+    console.log(response.body)
+    client.test("echoed", function() {
+        client.assert(response.body === "ping", "unexpected body")
+    })
+%}
+
+### missing
+GET http://localhost/{{missing}}
+`
+
+func TestReportJSON(t *testing.T) {
+	p, err := ParseString(twoSteps)
+	require.NoError(t, err)
+	p.Handler = http.HandlerFunc(echoHandler)
+	p.SetVariable("host", "localhost")
+	p.SetVariable("word", "pong")
+	p.ContinueOnError = true
+	report, err := p.Play()
+	require.NoError(t, err)
+
+	var sb strings.Builder
+	require.NoError(t, WriteJSON(&sb, report))
+	var doc JSONReports
+	require.NoError(t, json.Unmarshal([]byte(sb.String()), &doc))
+	assert.Equal(t, JSONSchemaVersion, doc.SchemaVersion)
+	require.Len(t, doc.Files, 1)
+	r := doc.Files[0]
+	assert.True(t, r.Failed)
+	assert.False(t, r.Started.IsZero())
+	require.Len(t, r.Steps, 2)
+
+	echo := r.Steps[0]
+	assert.Equal(t, "echo", echo.Name)
+	assert.Equal(t, "POST", echo.Method)
+	assert.Equal(t, "http://localhost/echo", echo.URL)
+	assert.Equal(t, http.StatusOK, echo.Status)
+	assert.Equal(t, "text/plain", echo.RequestHeaders.Get("Content-Type"))
+	assert.Contains(t, echo.ResponseHandlerOutput, "pong\n")
+	assert.Positive(t, echo.DurationMs)
+	require.Len(t, echo.Tests, 1)
+	assert.Equal(t, JSONTest{
+		Name:       "echoed",
+		Message:    "unexpected body",
+		Location:   ":10:22",
+		DurationMs: echo.Tests[0].DurationMs,
+	}, echo.Tests[0])
+	assert.Equal(t, &JSONError{Kind: "assertion", Message: "1 of 1 tests failed"}, echo.Error)
+
+	missing := r.Steps[1]
+	assert.Equal(t, "GET", missing.Method)
+	assert.Equal(t, "http://localhost/{{missing}}", missing.URL)
+	assert.Zero(t, missing.Status)
+	assert.Equal(t, &JSONError{Kind: "request", Message: "unresolved variables: missing"}, missing.Error)
+}
+
+func TestNDJSONEvents(t *testing.T) {
+	p, err := ParseString(twoSteps)
+	require.NoError(t, err)
+	p.Handler = http.HandlerFunc(echoHandler)
+	p.SetVariable("host", "localhost")
+	p.SetVariable("word", "ping")
+	var sb strings.Builder
+	w := NewNDJSONWriter(&sb)
+	p.Observer = w.Event
+	_, err = p.Play()
+	assert.Error(t, err)
+	require.NoError(t, w.Err())
+
+	var events []Event
+	scanner := bufio.NewScanner(strings.NewReader(sb.String()))
+	for scanner.Scan() {
+		var e Event
+		require.NoError(t, json.Unmarshal(scanner.Bytes(), &e), scanner.Text())
+		events = append(events, e)
+	}
+	var types []EventType
+	for _, e := range events {
+		types = append(types, e.Type)
+	}
+	assert.Equal(t, []EventType{
+		EventPlayStart,
+		EventStepStart, EventStepFinish,
+		EventStepStart, EventStepFinish,
+		EventPlayFinish,
+	}, types)
+	assert.Equal(t, "echo", events[1].Step.Name)
+	assert.Nil(t, events[1].Step.Tests)
+	assert.True(t, events[2].Step.Tests[0].Passed)
+	// Variables are expanded when the request is created, after the start event.
+	assert.Equal(t, "http://{{host}}/echo", events[1].Step.URL)
+	assert.Equal(t, "http://localhost/echo", events[2].Step.URL)
+	assert.Equal(t, "request", events[4].Step.Error.Kind)
+	require.NotNil(t, events[5].Failed)
+	assert.True(t, *events[5].Failed)
+}
+
+type failingWriter struct{}
+
+func (failingWriter) Write([]byte) (int, error) {
+	return 0, errors.New("disk is full")
+}
+
+func TestNDJSONWriterError(t *testing.T) {
+	w := NewNDJSONWriter(failingWriter{})
+	w.Event(Event{Type: EventPlayStart})
+	w.Event(Event{Type: EventPlayFinish})
+	assert.EqualError(t, w.Err(), "disk is full")
+}
@@ -0,0 +1,132 @@
+package gpc
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+)
+
+type junitTestSuites struct {
+	XMLName  xml.Name         `xml:"testsuites"`
+	Tests    int              `xml:"tests,attr"`
+	Failures int              `xml:"failures,attr"`
+	Errors   int              `xml:"errors,attr"`
+	Time     string           `xml:"time,attr"`
+	Suites   []junitTestSuite `xml:"testsuite"`
+}
+
+type junitTestSuite struct {
+	Name      string          `xml:"name,attr"`
+	Tests     int             `xml:"tests,attr"`
+	Failures  int             `xml:"failures,attr"`
+	Errors    int             `xml:"errors,attr"`
+	Time      string          `xml:"time,attr"`
+	Timestamp string          `xml:"timestamp,attr,omitempty"`
+	Cases     []junitTestCase `xml:"testcase"`
+	SystemOut string          `xml:"system-out,omitempty"`
+}
+
+type junitTestCase struct {
+	Name      string        `xml:"name,attr"`
+	ClassName string        `xml:"classname,attr"`
+	Time      string        `xml:"time,attr"`
+	Failure   *junitProblem `xml:"failure,omitempty"`
+	Error     *junitProblem `xml:"error,omitempty"`
+}
+
+type junitProblem struct {
+	Message string `xml:"message,attr"`
+	Type    string `xml:"type,attr"`
+	Text    string `xml:",chardata"`
+}
+
+// WriteJUnit writes the reports as JUnit XML: one testsuite per .http file and one testcase per client.test,
+// the name of the request is used as the class name. Requests without tests and requests that failed
+// before their tests run are reported as testcases too. Console output of the scripts goes to system-out.
+func WriteJUnit(w io.Writer, reports ...Report) error {
+	suites := junitTestSuites{}
+	var total time.Duration
+	for _, r := range reports {
+		suite, duration := r.junitSuite()
+		suites.Suites = append(suites.Suites, suite)
+		suites.Tests += suite.Tests
+		suites.Failures += suite.Failures
+		suites.Errors += suite.Errors
+		total += duration
+	}
+	suites.Time = junitTime(total)
+
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "  ")
+	if err := enc.Encode(suites); err != nil {
+		return err
+	}
+	_, err := io.WriteString(w, "\n")
+	return err
+}
+
+func (r Report) junitSuite() (junitTestSuite, time.Duration) {
+	suite := junitTestSuite{Name: r.file}
+	if !r.started.IsZero() {
+		suite.Timestamp = r.started.UTC().Format("2006-01-02T15:04:05")
+	}
+	var total time.Duration
+	var out strings.Builder
+	for _, s := range r.steps {
+		total += s.duration
+		className := s.Name()
+		for _, t := range s.TestResults() {
+			tc := junitTestCase{Name: t.Name, ClassName: className, Time: junitTime(t.Duration)}
+			if !t.Passed {
+				tc.Failure = &junitProblem{
+					Message: t.Message,
+					Type:    AssertionFailure.String(),
+					Text:    testFailureText(t),
+				}
+				suite.Failures++
+			}
+			suite.Cases = append(suite.Cases, tc)
+		}
+		if len(s.TestResults()) == 0 || s.err != nil && s.err.Kind != AssertionFailure {
+			tc := junitTestCase{Name: className, ClassName: className, Time: junitTime(s.duration)}
+			if s.err != nil {
+				tc.Error = &junitProblem{Message: s.err.Error(), Type: s.err.Kind.String()}
+				suite.Errors++
+			}
+			suite.Cases = append(suite.Cases, tc)
+		}
+		for _, console := range []string{s.PreRequestHandlerOutput(), s.ResponseHandlerOutput()} {
+			if console != "" {
+				fmt.Fprintf(&out, "=== %s\n%s", className, console)
+				if !strings.HasSuffix(console, "\n") {
+					out.WriteString("\n")
+				}
+			}
+		}
+	}
+	suite.Tests = len(suite.Cases)
+	suite.Time = junitTime(total)
+	suite.SystemOut = out.String()
+	return suite, total
+}
+
+func testFailureText(t TestResult) string {
+	text := t.Message
+	if loc := t.Location(); loc != "" {
+		text = loc + ": " + text
+	}
+	if t.Stack != "" {
+		text += "\n" + t.Stack
+	}
+	return text
+}
+
+// junitTime formats the duration as seconds.
+func junitTime(d time.Duration) string {
+	return fmt.Sprintf("%.3f", d.Seconds())
+}
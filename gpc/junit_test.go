@@ -0,0 +1,88 @@
+package gpc
+
+import (
+	"errors"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWriteJUnit(t *testing.T) {
+	report := Report{
+		file:    "users.http",
+		started: time.Date(2024, 7, 1, 10, 0, 0, 0, time.UTC),
+		steps: []StepResult{
+			{
+				step:     step{name: "create user"},
+				duration: 1500 * time.Millisecond,
+				rhResult: executeResult{
+					console: "created 42\n",
+					tests: []TestResult{
+						{Name: "status is 201", Passed: true, Duration: 2 * time.Millisecond},
+						{
+							Name: "has id", Message: "id is missing", Stack: "at users.http:9:20",
+							File: "users.http", Line: 9, Column: 20, Duration: time.Millisecond,
+						},
+					},
+				},
+				err: &StepError{Kind: AssertionFailure, Err: errors.New("1 of 2 tests failed")},
+			},
+			{
+				step:     step{method: "GET", url: "http://localhost/users"},
+				duration: 500 * time.Millisecond,
+				err:      &StepError{Kind: TransportError, Err: errors.New("connection refused")},
+			},
+		},
+	}
+	var sb strings.Builder
+	require.NoError(t, WriteJUnit(&sb, report))
+	assert.Equal(t, `<?xml version="1.0" encoding="UTF-8"?>
+<testsuites tests="3" failures="1" errors="1" time="2.000">
+  <testsuite name="users.http" tests="3" failures="1" errors="1" time="2.000" timestamp="2024-07-01T10:00:00">
+    <testcase name="status is 201" classname="create user" time="0.002"></testcase>
+    <testcase name="has id" classname="create user" time="0.001">
+      <failure message="id is missing" type="assertion failure">users.http:9:20: id is missing&#xA;at users.http:9:20</failure>
+    </testcase>
+    <testcase name="GET http://localhost/users" classname="GET http://localhost/users" time="0.500">
+      <error message="connection refused" type="transport error"></error>
+    </testcase>
+    <system-out>=== create user&#xA;created 42&#xA;</system-out>
+  </testsuite>
+</testsuites>
+`, sb.String())
+}
+
+func TestWriteJUnitPlayedFile(t *testing.T) {
+	recipe := filepath.Join(t.TempDir(), "echo.http")
+	require.NoError(t, os.WriteFile(recipe, []byte(`### echo
+POST http://localhost/echo
+
+ping
+
+> {%
+    console.log(response.body)
+    client.test("echoed", function() {
+        client.assert(response.body === "ping", "unexpected body")
+    })
+%}
+`), 0644))
+	p, err := ParseFile(recipe)
+	require.NoError(t, err)
+	p.Handler = http.HandlerFunc(echoHandler)
+	report, err := p.Play()
+	require.NoError(t, err)
+	assert.Equal(t, recipe, report.File())
+	assert.Positive(t, report.Steps()[0].Duration())
+
+	var sb strings.Builder
+	require.NoError(t, WriteJUnit(&sb, report))
+	assert.Contains(t, sb.String(), `<testsuite name="`+recipe+`" tests="1" failures="0" errors="0"`)
+	assert.Contains(t, sb.String(), `<testcase name="echoed" classname="echo"`)
+	assert.Contains(t, sb.String(), "<system-out>=== echo&#xA;ping&#xA;RUN: echoed")
+}
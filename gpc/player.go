@@ -2,52 +2,158 @@ package gpc
 
 import (
 	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
 	"io"
 	"net/http"
-	"net/url"
 	"os"
+	"path/filepath"
 	"strings"
-	"testing"
-
-	"github.com/stretchr/testify/assert"
+	"time"
 
 	"github.com/strotz/goplaycalls/pipes"
 )
 
 type Player struct {
-	steps  []step
-	report Report
-	Dialer pipes.DialerFunc
+	steps    []step
+	report   Report
+	baseDir  string // Directory used to resolve files referenced from the recipe.
+	fileName string // Name of .http file used in script stack traces.
+	Dialer   pipes.DialerFunc
+	Handler  http.Handler // Serves requests in-process when set, no connections are made.
+	// ContinueOnError plays all steps even if some of them fail, errors are recorded on the steps.
+	ContinueOnError bool
+	// Observer is called synchronously as the play and each step start and finish.
+	Observer func(Event)
+
+	variables   map[string]string // Values for {{name}} placeholders.
+	environment map[string]string // Values from the active environment.
+	globals     *globalStore      // Values of client.global kept across steps.
+	scripts     map[string]string // Content of script files by path, loaded once.
+}
+
+// SetVariable sets the value that replaces {{name}} placeholders in URL, headers and body of the requests.
+func (p *Player) SetVariable(name, value string) {
+	if p.variables == nil {
+		p.variables = make(map[string]string)
+	}
+	p.variables[name] = value
+}
+
+// UseEnvironment activates the environment declared in http-client.env.json and
+// http-client.private.env.json files next to the .http file. Values set with SetVariable take precedence.
+func (p *Player) UseEnvironment(name string) error {
+	env, err := loadEnvironment(p.baseDir, name)
+	if err != nil {
+		return err
+	}
+	p.environment = env
+	return nil
+}
+
+// lookup returns the value of the variable.
+func (p *Player) lookup(name string) (string, bool) {
+	if v, ok := p.variables[name]; ok {
+		return v, true
+	}
+	if v, ok := p.report.lookupReference(name); ok {
+		return v, true
+	}
+	if v, ok := p.globals.lookup(name); ok {
+		return v, true
+	}
+	v, ok := p.environment[name]
+	return v, ok
 }
 
-type execStep struct {
-	step step // Definition of a step.
-	req  *http.Request
-	res  *http.Response
+// StepResult is the outcome of a played request: the request and response, console output of the
+// scripts, results of client.test and the failure, if any.
+type StepResult struct {
+	step    step // Definition of a step.
+	req     *http.Request
+	reqBody string // Body sent with req, empty when it is streamed from the body file.
+	res     *http.Response
+	resBody []byte // Body of the response, the body of res is already consumed.
 
+	prResult executeResult
 	rhResult executeResult
+	err      *StepError // Failure of the step.
+	duration time.Duration
+}
+
+// Name returns the name of the request from the separator, or its method and URL.
+func (e StepResult) Name() string {
+	return e.step.displayName()
+}
+
+// Duration returns the time spent to play the step, including scripts.
+func (e StepResult) Duration() time.Duration {
+	return e.duration
+}
+
+// Err returns the failure of the step as *StepError or nil when the step passed.
+func (e StepResult) Err() error {
+	if e.err == nil {
+		return nil
+	}
+	return e.err
+}
+
+// failedTests returns the number of failed client.test.
+func (e StepResult) failedTests() int {
+	failed := 0
+	for _, t := range e.rhResult.tests {
+		if !t.Passed {
+			failed++
+		}
+	}
+	return failed
+}
+
+func (e StepResult) PreRequestHandlerOutput() string {
+	return e.prResult.console
 }
 
-func (e execStep) ResponseHandlerOutput() string {
+func (e StepResult) ResponseHandlerOutput() string {
 	return e.rhResult.console
 }
 
-func (e execStep) ResponseHandlerTestErrors() []string {
+func (e StepResult) ResponseHandlerTestErrors() []string {
 	return e.rhResult.failures
 }
 
-func (e execStep) Failed() bool {
-	return len(e.rhResult.failures) > 0
+// TestResults returns the result of each client.test declared by the response handler.
+func (e StepResult) TestResults() []TestResult {
+	return e.rhResult.tests
+}
+
+func (e StepResult) Failed() bool {
+	return e.err != nil
 }
 
 type Report struct {
-	steps []execStep
+	file    string    // Path of the played .http file, empty when it is not parsed from a file.
+	started time.Time // Time when the play started.
+	steps   []StepResult
+}
+
+// File returns the path of the played .http file.
+func (r Report) File() string {
+	return r.file
 }
 
-func (r Report) Steps() []execStep {
+// Steps returns the results of the played requests in the order they were played.
+func (r Report) Steps() []StepResult {
 	return r.steps
 }
 
+// newReport starts the report of the play.
+func (p *Player) newReport() Report {
+	return Report{file: p.fileName, started: time.Now()}
+}
+
 func (r Report) TestFailed() bool {
 	for _, step := range r.steps {
 		if step.Failed() {
@@ -57,41 +163,229 @@ func (r Report) TestFailed() bool {
 	return false
 }
 
+// Play executes all steps.
 func (p *Player) Play() (Report, error) {
-	report := Report{}
+	return p.PlayContext(context.Background())
+}
+
+// PlayContext executes all steps, it stops when the context is done. A step that exceeds the time limit
+// declared with # @timeout or # @connection-timeout is recorded in the report as failed. Other errors stop
+// the play, unless ContinueOnError is set. The failed step is always included in the report.
+func (p *Player) PlayContext(ctx context.Context) (Report, error) {
+	err := p.play(ctx, func(_ step, play func() StepResult) {
+		if play != nil {
+			play()
+		}
+	})
+	return p.report, err
+}
+
+// play starts a new report and calls each for every step with the function that plays the step and
+// records it in the report, the step is not played unless each calls it. After the context is done or
+// a failure stops the play, each is called for the remaining steps with nil function. play returns
+// the error that stopped the play.
+func (p *Player) play(ctx context.Context, each func(step step, play func() StepResult)) error {
+	p.report = p.newReport()
+	p.emit(EventPlayStart, nil)
+	defer p.emit(EventPlayFinish, nil)
+	cl := p.newClient()
+	// The transport is created per play, its keep-alive connections are not reused later.
+	defer cl.CloseIdleConnections()
+	var stopErr error
+	for _, step := range p.steps {
+		if stopErr != nil {
+			each(step, nil)
+			continue
+		}
+		each(step, func() StepResult {
+			item := p.playStep(ctx, cl, step)
+			p.report.steps = append(p.report.steps, item)
+			if ctx.Err() != nil {
+				stopErr = ctx.Err()
+			} else if p.stops(item) {
+				stopErr = item.err
+			}
+			return item
+		})
+	}
+	return stopErr
+}
+
+// stops reports whether the play has to stop after the step.
+func (p *Player) stops(item StepResult) bool {
+	return item.err != nil && !p.ContinueOnError && item.err.stopsPlay()
+}
+
+// newClient creates http client used to play all steps.
+func (p *Player) newClient() *http.Client {
 	cl := &http.Client{}
-	if p.Dialer != nil {
-		cl.Transport = &http.Transport{
-			DialContext: p.Dialer,
+	if p.Handler != nil {
+		cl.Transport = handlerTransport{handler: p.Handler}
+		return cl
+	}
+	cl.Transport = newTransport(p.Dialer)
+	return cl
+}
+
+// playStep executes the step: runs pre-request handler, sends the request and runs response handler.
+// Failures are recorded on the returned step.
+func (p *Player) playStep(ctx context.Context, cl *http.Client, step step) (item StepResult) {
+	item.step = step
+	p.emit(EventStepStart, &JSONStep{Name: step.displayName(), Method: step.method, URL: step.url})
+	start := time.Now()
+	defer func() {
+		item.duration = time.Since(start)
+		if p.Observer != nil {
+			res := item.JSON()
+			p.emit(EventStepFinish, &res)
 		}
+	}()
+	fail := func(kind StepErrorKind, err error) StepResult {
+		item.err = &StepError{Kind: kind, Err: err}
+		return item
 	}
-	for _, step := range p.steps {
-		item := execStep{
-			step: step,
+
+	timeout, connectionTimeout, err := stepTimeouts(step)
+	if err != nil {
+		return fail(RequestError, err)
+	}
+	reqCtx := withConnectionTimeout(ctx, connectionTimeout)
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		reqCtx, cancel = context.WithTimeout(reqCtx, timeout)
+		defer cancel()
+	}
+	variables := newRequestVariables()
+	lookup := func(name string) (string, bool) {
+		if v, ok := variables.lookup(name); ok {
+			return v, true
 		}
-		u, err := url.Parse(step.url)
+		return p.lookup(name)
+	}
+	if step.preRequestHandler != nil {
+		request := newRequestAdapter(step, variables, p.environment, lookup)
+		source, err := p.scriptSource(step.preRequestHandler)
+		if err != nil {
+			return fail(ScriptError, err)
+		}
+		item.prResult, err = executePreRequestHandler(source, &playEnvironment{globals: p.globals}, request)
 		if err != nil {
-			return report, err
+			return fail(ScriptError, err)
 		}
-		item.req = &http.Request{
-			Method: step.method,
-			URL:    u,
+	}
+	expanded, err := expandStep(step, lookup)
+	if err != nil {
+		return fail(RequestError, err)
+	}
+	item.req, err = p.newRequest(reqCtx, expanded)
+	if err != nil {
+		return fail(RequestError, err)
+	}
+	item.reqBody = expanded.body
+	for _, h := range expanded.headers {
+		if http.CanonicalHeaderKey(h.name) == "Host" {
+			item.req.Host = h.value
+			continue
 		}
-		item.res, err = cl.Do(item.req)
+		item.req.Header.Add(h.name, h.value)
+	}
+	item.res, err = cl.Do(item.req)
+	if err == nil {
+		item.resBody, err = readBody(item.res)
+	}
+	if err != nil {
+		return fail(TransportError, err)
+	}
+	if step.responseHandler != nil {
+		source, err := p.scriptSource(step.responseHandler)
 		if err != nil {
-			return report, err
+			return fail(ScriptError, err)
 		}
-		if step.responseHandler != nil {
-			r := results{}
-			item.rhResult, err = executeResponseHandler(step.responseHandler.content, nil, *item.res, &r)
-			if err != nil {
-				// TODO: add item to report?
-				return report, err
-			}
+		r := results{}
+		res := *item.res
+		res.Body = io.NopCloser(bytes.NewReader(item.resBody))
+		item.rhResult, err = executeResponseHandler(source, &playEnvironment{globals: p.globals}, res, &r)
+		if err != nil {
+			return fail(ScriptError, err)
 		}
-		report.steps = append(report.steps, item)
 	}
-	return report, nil
+	if failed := item.failedTests(); failed > 0 {
+		return fail(AssertionFailure, fmt.Errorf("%d of %d tests failed", failed, len(item.rhResult.tests)))
+	}
+	return item
+}
+
+// readBody reads and closes the body of the response. Responses to HEAD requests have no body.
+func readBody(res *http.Response) ([]byte, error) {
+	if res.Body == nil {
+		return nil, nil
+	}
+	defer res.Body.Close()
+	if res.Request != nil && res.Request.Method == http.MethodHead {
+		return nil, nil
+	}
+	return io.ReadAll(res.Body)
+}
+
+// newRequest creates http request for the step. Body of the request is streamed from the file if the step
+// refers to one.
+func (p *Player) newRequest(ctx context.Context, s step) (*http.Request, error) {
+	if s.bodyFile == "" {
+		var body io.Reader
+		if s.body != "" {
+			body = strings.NewReader(s.body)
+		}
+		return http.NewRequestWithContext(ctx, s.method, s.url, body)
+	}
+
+	f, err := os.Open(p.resolvePath(s.bodyFile))
+	if err != nil {
+		return nil, err
+	}
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	req, err := http.NewRequestWithContext(ctx, s.method, s.url, f)
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	req.ContentLength = info.Size()
+	if req.ContentLength == 0 {
+		req.Body = http.NoBody
+		f.Close()
+	}
+	return req, nil
+}
+
+// scriptSource returns the code of the handler, either embedded or loaded from the file.
+func (p *Player) scriptSource(s *script) (scriptCode, error) {
+	if s.file == "" {
+		return embeddedScriptCode(p.fileName, s.content, s.pos), nil
+	}
+	path := p.resolvePath(s.file)
+	if source, ok := p.scripts[path]; ok {
+		return scriptCode{name: path, source: source}, nil
+	}
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return scriptCode{}, fmt.Errorf("failed to load script %s: %w", path, err)
+	}
+	if p.scripts == nil {
+		p.scripts = make(map[string]string)
+	}
+	p.scripts[path] = string(b)
+	return scriptCode{name: path, source: p.scripts[path]}, nil
+}
+
+// resolvePath returns path of the file referenced from the recipe.
+func (p *Player) resolvePath(path string) string {
+	if filepath.IsAbs(path) {
+		return path
+	}
+	return filepath.Join(p.baseDir, path)
 }
 
 // ParseFile creates a new Player for http request file.
@@ -101,7 +395,17 @@ func ParseFile(filePath string) (*Player, error) {
 		return nil, err
 	}
 	defer f.Close()
-	return newPlayer(bufio.NewReader(f))
+	p, err := newPlayer(bufio.NewReader(f))
+	var diags Diagnostics
+	if errors.As(err, &diags) {
+		return nil, diags.WithFile(filePath)
+	}
+	if err != nil {
+		return nil, err
+	}
+	p.baseDir = filepath.Dir(filePath)
+	p.fileName = filePath
+	return p, nil
 }
 
 func ParseString(data string) (*Player, error) {
@@ -115,28 +419,7 @@ func newPlayer(r io.Reader) (*Player, error) {
 		return nil, err
 	}
 	return &Player{
-		steps: steps,
+		steps:   steps,
+		globals: newGlobalStore(),
 	}, nil
 }
-
-func RunTests(filePath string, t *testing.T) Report {
-	p, err := ParseFile(filePath)
-	if err != nil {
-		t.Fatal(err)
-	}
-	report, err := p.Play()
-	assert.NoError(t, err)
-	// TODO: output in one of the common formats
-	// TODO:extract stack trace and make line:pos real
-	if report.TestFailed() {
-		for _, step := range report.Steps() {
-			t.Logf("test console:\n%s", step.ResponseHandlerOutput())
-			f := step.ResponseHandlerTestErrors()
-			for _, failure := range f {
-				t.Logf("failure:\n%s", failure)
-			}
-		}
-		assert.Fail(t, "at least one test failed")
-	}
-	return report
-}
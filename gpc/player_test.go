@@ -1,9 +1,15 @@
 package gpc
 
 import (
+	"io"
 	"log"
 	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"runtime"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
@@ -13,8 +19,16 @@ import (
 )
 
 func echoHandler(response http.ResponseWriter, req *http.Request) {
-	// TODO: it is echo service, need to return something :)
 	log.Println("Method:", req.Method)
+	_, err := io.Copy(response, req.Body)
+	if err != nil {
+		http.Error(response, err.Error(), http.StatusInternalServerError)
+	}
+}
+
+// newEchoServer creates test server that echoes requests to any path.
+func newEchoServer(name string) *testserver.TestServer {
+	return testserver.NewHandlerTestServer(name, http.HandlerFunc(echoHandler))
 }
 
 func TestCallGetRequest(t *testing.T) {
@@ -46,3 +60,429 @@ PUT http://localhost:8080/b
 	assert.NoError(t, err)
 	assert.False(t, r.TestFailed())
 }
+
+func TestCallWithHeaders(t *testing.T) {
+	var got http.Header
+	ts := testserver.NewTestServer(t.Name(), http.MethodGet, "/h", func(response http.ResponseWriter, req *http.Request) {
+		got = req.Header.Clone()
+	})
+	ts.Start()
+	t.Cleanup(ts.Stop)
+
+	p, err := ParseString(`### Get with headers
+GET http://localhost:8080/h
+Authorization: Bearer secret
+Accept: application/json
+`)
+	require.NoError(t, err)
+	p.Dialer = pipes.CreateDialer(t.Name())
+	_, err = p.Play()
+	require.NoError(t, err)
+	assert.Equal(t, "Bearer secret", got.Get("Authorization"))
+	assert.Equal(t, "application/json", got.Get("Accept"))
+}
+
+func TestCallPostRequestWithBody(t *testing.T) {
+	var contentLength int64
+	ts := testserver.NewTestServer(t.Name(), http.MethodPost, "/c", func(response http.ResponseWriter, req *http.Request) {
+		contentLength = req.ContentLength
+		echoHandler(response, req)
+	})
+	ts.Start()
+	t.Cleanup(ts.Stop)
+
+	p, err := ParseString(`### Post operation
+POST http://localhost:8080/c
+Content-Type: application/json
+
+{
+  "name": "Hello"
+}
+
+> {%
+client.test("echo", function() {
+	client.assert(response.body === '{\n  "name": "Hello"\n}', "unexpected body: " + response.body);
+});
+%}
+`)
+	require.NoError(t, err)
+	p.Dialer = pipes.CreateDialer(t.Name())
+	r, err := p.Play()
+	require.NoError(t, err)
+	assert.False(t, r.TestFailed(), r.Steps()[0].ResponseHandlerOutput())
+	assert.Equal(t, int64(len("{\n  \"name\": \"Hello\"\n}")), contentLength)
+}
+
+func TestCallPostRequestWithBodyFile(t *testing.T) {
+	var contentLength int64
+	ts := testserver.NewTestServer(t.Name(), http.MethodPost, "/d", func(response http.ResponseWriter, req *http.Request) {
+		contentLength = req.ContentLength
+		echoHandler(response, req)
+	})
+	ts.Start()
+	t.Cleanup(ts.Stop)
+
+	dir := t.TempDir()
+	payload := `{"name": "Hello"}`
+	require.NoError(t, os.MkdirAll(filepath.Join(dir, "fixtures"), 0755))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "fixtures", "payload.json"), []byte(payload), 0644))
+	recipe := filepath.Join(dir, "post.http")
+	require.NoError(t, os.WriteFile(recipe, []byte(`### Post operation
+POST http://localhost:8080/d
+Content-Type: application/json
+
+< ./fixtures/payload.json
+
+> {%
+client.test("echo", function() {
+	client.assert(response.body === '{"name": "Hello"}', "unexpected body: " + response.body);
+});
+%}
+`), 0644))
+
+	p, err := ParseFile(recipe)
+	require.NoError(t, err)
+	p.Dialer = pipes.CreateDialer(t.Name())
+	r, err := p.Play()
+	require.NoError(t, err)
+	assert.False(t, r.TestFailed(), r.Steps()[0].ResponseHandlerOutput())
+	assert.Equal(t, int64(len(payload)), contentLength)
+}
+
+func TestCallPostRequestWithXMLBody(t *testing.T) {
+	p, err := ParseString(`POST http://localhost/xml
+Content-Type: application/xml
+
+<note><to>a</to></note>
+
+> {%
+client.test("echo", function() {
+	client.assert(response.body === "<note><to>a</to></note>", "unexpected body: " + response.body);
+});
+%}
+`)
+	require.NoError(t, err)
+	p.Handler = http.HandlerFunc(echoHandler)
+	r, err := p.Play()
+	require.NoError(t, err)
+	assert.False(t, r.TestFailed(), r.Steps()[0].ResponseHandlerOutput())
+}
+
+func TestCallPostRequestWithMissingBodyFile(t *testing.T) {
+	p, err := ParseString(`POST http://localhost:8080/d
+
+< ./does-not-exist.json
+`)
+	require.NoError(t, err)
+	_, err = p.Play()
+	assert.ErrorIs(t, err, os.ErrNotExist)
+}
+
+func TestCallHeadRequest(t *testing.T) {
+	ts := testserver.NewTestServer(t.Name(), http.MethodHead, "/e", func(response http.ResponseWriter, req *http.Request) {
+		response.Header().Set("Content-Length", "42")
+	})
+	ts.Start()
+	t.Cleanup(ts.Stop)
+
+	p, err := ParseString(`### Head operation
+HEAD http://localhost:8080/e
+
+> {%
+client.test("no body", function() {
+	client.assert(response.status === 200, "unexpected status");
+	client.assert(response.body === "", "unexpected body");
+});
+%}
+`)
+	require.NoError(t, err)
+	p.Dialer = pipes.CreateDialer(t.Name())
+	r, err := p.Play()
+	require.NoError(t, err)
+	assert.False(t, r.TestFailed(), r.Steps()[0].ResponseHandlerOutput())
+}
+
+func TestCallCustomMethod(t *testing.T) {
+	ts := testserver.NewTestServer(t.Name(), "PROPFIND", "/f", echoHandler)
+	ts.Start()
+	t.Cleanup(ts.Stop)
+
+	p, err := ParseString(`### WebDAV operation
+PROPFIND http://localhost:8080/f
+
+> {%
+client.test("found", function() {
+	client.assert(response.status === 200, "unexpected status");
+});
+%}
+`)
+	require.NoError(t, err)
+	p.Dialer = pipes.CreateDialer(t.Name())
+	r, err := p.Play()
+	require.NoError(t, err)
+	assert.False(t, r.TestFailed(), r.Steps()[0].ResponseHandlerOutput())
+}
+
+func TestCallWithVariables(t *testing.T) {
+	var auth string
+	ts := testserver.NewTestServer(t.Name(), http.MethodGet, "/users/{id}", func(response http.ResponseWriter, req *http.Request) {
+		auth = req.Header.Get("Authorization")
+		_, _ = io.WriteString(response, req.PathValue("id"))
+	})
+	ts.Start()
+	t.Cleanup(ts.Stop)
+
+	p, err := ParseString(`### Get user
+GET {{host}}/users/{{id}}
+Authorization: Bearer {{token}}
+
+> {%
+client.test("user", function() {
+	client.assert(response.body === "42", "unexpected body: " + response.body);
+});
+%}
+`)
+	require.NoError(t, err)
+	p.Dialer = pipes.CreateDialer(t.Name())
+
+	_, err = p.Play()
+	assert.EqualError(t, err, "unresolved variables: host, id, token")
+
+	p.SetVariable("host", "http://localhost:8080")
+	p.SetVariable("id", "42")
+	p.SetVariable("token", "secret")
+	r, err := p.Play()
+	require.NoError(t, err)
+	assert.False(t, r.TestFailed(), r.Steps()[0].ResponseHandlerOutput())
+	assert.Equal(t, "Bearer secret", auth)
+}
+
+func TestCallWithResponseReferences(t *testing.T) {
+	sm := http.NewServeMux()
+	sm.HandleFunc("POST /users", func(response http.ResponseWriter, req *http.Request) {
+		response.Header().Set("Location", "/users/42")
+		response.Header().Set("Content-Type", "application/json")
+		_, _ = io.WriteString(response, `{"id": 42, "name": "Hello"}`)
+	})
+	sm.HandleFunc("GET /users/{id}", func(response http.ResponseWriter, req *http.Request) {
+		_, _ = io.WriteString(response, req.PathValue("id")+" "+req.Header.Get("X-Location"))
+	})
+	ts := testserver.NewHandlerTestServer(t.Name(), sm)
+	ts.Start()
+	t.Cleanup(ts.Stop)
+
+	p, err := ParseString(`### Create user
+# @name createUser
+POST http://localhost:8080/users
+
+### Fetch user
+GET http://localhost:8080/users/{{createUser.response.body.$.id}}
+X-Location: {{createUser.response.headers.Location}}
+
+> {%
+client.test("fetched", function() {
+	client.assert(response.body === "42 /users/42", "unexpected body: " + response.body);
+});
+%}
+`)
+	require.NoError(t, err)
+	p.Dialer = pipes.CreateDialer(t.Name())
+	r, err := p.Play()
+	require.NoError(t, err)
+	require.Len(t, r.Steps(), 2)
+	assert.False(t, r.TestFailed(), r.Steps()[1].ResponseHandlerOutput())
+}
+
+func TestCallWithGlobals(t *testing.T) {
+	sm := http.NewServeMux()
+	sm.HandleFunc("POST /login", func(response http.ResponseWriter, req *http.Request) {
+		_, _ = io.WriteString(response, "secret")
+	})
+	sm.HandleFunc("GET /profile", func(response http.ResponseWriter, req *http.Request) {
+		if req.Header.Get("Authorization") != "Bearer secret" {
+			http.Error(response, "unauthorized", http.StatusUnauthorized)
+		}
+	})
+	ts := testserver.NewHandlerTestServer(t.Name(), sm)
+	ts.Start()
+	t.Cleanup(ts.Stop)
+
+	p, err := ParseString(`### Login
+POST http://localhost:8080/login
+
+> {%
+client.global.set("auth_token", response.body);
+%}
+
+### Profile
+GET http://localhost:8080/profile
+Authorization: Bearer {{auth_token}}
+
+> {%
+client.test("authorized", function() {
+	client.assert(response.status === 200, "unexpected status: " + response.status);
+});
+%}
+`)
+	require.NoError(t, err)
+	p.Dialer = pipes.CreateDialer(t.Name())
+	r, err := p.Play()
+	require.NoError(t, err)
+	require.Len(t, r.Steps(), 2)
+	assert.False(t, r.TestFailed(), r.Steps()[1].ResponseHandlerOutput())
+}
+
+func TestCallWithPreRequestHandler(t *testing.T) {
+	var signature string
+	ts := testserver.NewTestServer(t.Name(), http.MethodPost, "/signed/{id}", func(response http.ResponseWriter, req *http.Request) {
+		signature = req.Header.Get("X-Signature")
+		_, _ = io.WriteString(response, req.PathValue("id"))
+	})
+	ts.Start()
+	t.Cleanup(ts.Stop)
+
+	p, err := ParseString(`### Signed call
+< {%
+	request.variables.set("id", "42");
+	request.variables.set("signature", request.method + " " + request.body.getRaw());
+	console.log("signing", request.url.tryGetSubstituted());
+%}
+POST http://localhost:8080/signed/{{id}}
+X-Signature: {{signature}}
+
+payload
+
+> {%
+client.test("signed", function() {
+	client.assert(response.body === "42", "unexpected body: " + response.body);
+});
+%}
+`)
+	require.NoError(t, err)
+	p.Dialer = pipes.CreateDialer(t.Name())
+	r, err := p.Play()
+	require.NoError(t, err)
+	assert.False(t, r.TestFailed(), r.Steps()[0].ResponseHandlerOutput())
+	assert.Equal(t, "POST payload", signature)
+	assert.Equal(t, "signing http://localhost:8080/signed/42\n", r.Steps()[0].PreRequestHandlerOutput())
+}
+
+func TestCallWithResponseHandlerFile(t *testing.T) {
+	pipeName := t.Name()
+	ts := testserver.NewTestServer(pipeName, http.MethodGet, "/g", echoHandler)
+	ts.Start()
+	t.Cleanup(ts.Stop)
+
+	dir := t.TempDir()
+	require.NoError(t, os.MkdirAll(filepath.Join(dir, "scripts"), 0755))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "scripts", "status.js"), []byte(`
+client.test("status", function() {
+	client.assert(response.status === 200, "unexpected status");
+});
+`), 0644))
+	recipe := filepath.Join(dir, "get.http")
+	require.NoError(t, os.WriteFile(recipe, []byte(`### First
+GET http://localhost:8080/g
+
+> scripts/status.js
+
+### Second
+GET http://localhost:8080/g
+
+> ./scripts/status.js
+`), 0644))
+
+	p, err := ParseFile(recipe)
+	require.NoError(t, err)
+	p.Dialer = pipes.CreateDialer(pipeName)
+	r, err := p.Play()
+	require.NoError(t, err)
+	require.Len(t, r.Steps(), 2)
+	for _, s := range r.Steps() {
+		assert.Equal(t, "RUN: status\nPASS: status\n", s.ResponseHandlerOutput())
+	}
+	assert.Len(t, p.scripts, 1)
+
+	t.Run("missing file", func(t *testing.T) {
+		p, err := ParseString(`GET http://localhost:8080/g
+
+> missing.js
+`)
+		require.NoError(t, err)
+		p.Dialer = pipes.CreateDialer(pipeName)
+		_, err = p.Play()
+		assert.ErrorIs(t, err, os.ErrNotExist)
+		assert.ErrorContains(t, err, "failed to load script missing.js")
+	})
+}
+
+func TestScriptErrorPositions(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "check.js"), []byte(`client.test("from file", function() {
+    client.assert(false, "file failure");
+});
+`), 0644))
+	recipe := filepath.Join(dir, "positions.http")
+	require.NoError(t, os.WriteFile(recipe, []byte(`### Embedded
+GET http://in-process/a
+
+> {%
+    client.test("embedded", function() {
+        client.assert(response.status === 404, "embedded failure");
+    });
+%}
+
+### File
+GET http://in-process/a
+
+> check.js
+
+### Broken
+GET http://in-process/a
+
+> {% client.test("ok", function() {}); undefinedFunction(); %}
+`), 0644))
+
+	p, err := ParseFile(recipe)
+	require.NoError(t, err)
+	p.Handler = http.HandlerFunc(echoHandler)
+	p.ContinueOnError = true
+	r, err := p.Play()
+	require.NoError(t, err)
+	require.Len(t, r.Steps(), 3)
+
+	embedded := r.Steps()[0].TestResults()
+	require.Len(t, embedded, 1)
+	assert.Equal(t, recipe+":6:22", embedded[0].Location())
+
+	file := r.Steps()[1].TestResults()
+	require.Len(t, file, 1)
+	assert.Equal(t, filepath.Join(dir, "check.js")+":2:18", file[0].Location())
+
+	assert.ErrorContains(t, r.Steps()[2].Err(), recipe+":18:57")
+}
+
+func TestParseFileDiagnostics(t *testing.T) {
+	recipe := filepath.Join(t.TempDir(), "broken.http")
+	require.NoError(t, os.WriteFile(recipe, []byte(`GET example.com
+Accept application/json
+`), 0644))
+	_, err := ParseFile(recipe)
+	assert.EqualError(t, err, recipe+`:2:1: unexpected "Accept application/json"`)
+}
+
+func TestPlayClosesConnections(t *testing.T) {
+	s := httptest.NewServer(http.HandlerFunc(echoHandler))
+	t.Cleanup(s.Close)
+	p, err := ParseString("GET " + s.URL + "/a\n")
+	require.NoError(t, err)
+	before := runtime.NumGoroutine()
+	for i := 0; i < 20; i++ {
+		_, err := p.Play()
+		require.NoError(t, err)
+	}
+	// Each idle keep-alive connection would hold goroutines of both the client and the server.
+	assert.Eventually(t, func() bool {
+		return runtime.NumGoroutine() <= before+2
+	}, time.Second, 10*time.Millisecond, "idle connections are left open")
+}
@@ -0,0 +1,201 @@
+package gpc
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"strings"
+)
+
+// scriptIndent is used for the lines of embedded scripts.
+const scriptIndent = "    "
+
+// Fprint writes f to w in the canonical .http format: a blank line between requests, "### name"
+// separators, canonical header names, embedded scripts on their own indented lines and
+// indented JSON bodies. Comments are kept at their places relative to the other parts of the request.
+func Fprint(w io.Writer, f *File) error {
+	var buf bytes.Buffer
+	for i, r := range f.Requests {
+		if i > 0 {
+			buf.WriteString("\n")
+		}
+		printRequest(&buf, r, i == 0)
+	}
+	_, err := w.Write(buf.Bytes())
+	return err
+}
+
+// Format parses src and returns it in the canonical format, see Fprint.
+// Source without requests is returned unchanged.
+func Format(src []byte) ([]byte, error) {
+	f, err := Parse(bytes.NewReader(src))
+	if err != nil {
+		return nil, err
+	}
+	if len(f.Requests) == 0 {
+		return src, nil
+	}
+	var buf bytes.Buffer
+	if err := Fprint(&buf, f); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// element is a part of the request that is printed as a whole.
+type element struct {
+	pos   Position
+	print func(buf *bytes.Buffer)
+}
+
+func printRequest(buf *bytes.Buffer, r *Request, first bool) {
+	var elements []element
+	// Requests after the first one always start with the separator.
+	if !first || r.SeparatorPos.IsValid() || r.Name != "" {
+		elements = append(elements, element{r.SeparatorPos, func(buf *bytes.Buffer) {
+			separate(buf)
+			buf.WriteString(strings.TrimSpace(requestSeparator + " " + r.Name))
+			buf.WriteString("\n")
+		}})
+	}
+	if r.PreRequestHandler != nil {
+		elements = append(elements, element{r.PreRequestHandler.Pos, func(buf *bytes.Buffer) {
+			printScript(buf, preRequestHandlerStart, r.PreRequestHandler)
+		}})
+	}
+	requestLine := len(elements)
+	elements = append(elements, element{r.Pos, func(buf *bytes.Buffer) {
+		buf.WriteString(strings.TrimSpace(r.Method + " " + r.URL + " " + r.HTTPVersion))
+		buf.WriteString("\n")
+	}})
+	for _, h := range r.Headers {
+		elements = append(elements, element{h.Pos, func(buf *bytes.Buffer) {
+			buf.WriteString(http.CanonicalHeaderKey(h.Name) + ": " + h.Value)
+			buf.WriteString("\n")
+		}})
+	}
+	if r.Body != "" || r.BodyFile != "" {
+		elements = append(elements, element{r.BodyPos, func(buf *bytes.Buffer) {
+			buf.WriteString("\n")
+			if r.BodyFile != "" {
+				buf.WriteString(bodyFileStart + " " + r.BodyFile + "\n")
+				return
+			}
+			buf.WriteString(formatBody(r))
+			buf.WriteString("\n")
+		}})
+	}
+	if r.ResponseHandler != nil {
+		elements = append(elements, element{r.ResponseHandler.Pos, func(buf *bytes.Buffer) {
+			buf.WriteString("\n")
+			printScript(buf, responseHandlerStart, r.ResponseHandler)
+		}})
+	}
+
+	// Each comment goes before the first element that follows it in the source.
+	// Comments without position are placed before the request line.
+	before := make([][]Comment, len(elements)+1)
+	for _, c := range r.Comments {
+		at := requestLine
+		if c.Pos.IsValid() {
+			at = len(elements)
+			for i, e := range elements {
+				if e.pos.IsValid() && c.Pos.before(e.pos) {
+					at = i
+					break
+				}
+			}
+		}
+		before[at] = append(before[at], c)
+	}
+	for i, e := range elements {
+		printComments(buf, before[i])
+		e.print(buf)
+	}
+	printComments(buf, before[len(elements)])
+}
+
+func (p Position) before(other Position) bool {
+	return p.Line < other.Line || p.Line == other.Line && p.Column < other.Column
+}
+
+func printComments(buf *bytes.Buffer, comments []Comment) {
+	for _, c := range comments {
+		// Separators of blocks without request are kept as comments.
+		if strings.HasPrefix(c.Text, requestSeparator) {
+			separate(buf)
+		}
+		buf.WriteString(strings.TrimSpace(c.Text))
+		buf.WriteString("\n")
+	}
+}
+
+// separate puts a blank line before the separator unless it starts the file.
+func separate(buf *bytes.Buffer) {
+	if buf.Len() > 0 && !bytes.HasSuffix(buf.Bytes(), []byte("\n\n")) {
+		buf.WriteString("\n")
+	}
+}
+
+func printScript(buf *bytes.Buffer, start string, s *Script) {
+	if !s.IsEmbedded() {
+		buf.WriteString(start + " " + s.File + "\n")
+		return
+	}
+	buf.WriteString(start + " " + scriptStart + "\n")
+	for _, line := range reindent(s.Content) {
+		if line != "" {
+			buf.WriteString(scriptIndent + line)
+		}
+		buf.WriteString("\n")
+	}
+	buf.WriteString(scriptEnd + "\n")
+}
+
+// reindent splits the script into lines without leading and trailing blank lines
+// and removes the indentation common for all lines.
+func reindent(content string) []string {
+	lines := strings.Split(strings.ReplaceAll(content, "\r\n", "\n"), "\n")
+	for i, line := range lines {
+		lines[i] = strings.TrimRight(line, spaceChars)
+	}
+	for len(lines) > 0 && lines[0] == "" {
+		lines = lines[1:]
+	}
+	for len(lines) > 0 && lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+	indent, first := "", true
+	for _, line := range lines {
+		if line == "" {
+			continue
+		}
+		lineIndent := line[:len(line)-len(strings.TrimLeft(line, " \t"))]
+		if first {
+			indent, first = lineIndent, false
+			continue
+		}
+		for !strings.HasPrefix(lineIndent, indent) {
+			indent = indent[:len(indent)-1]
+		}
+	}
+	for i, line := range lines {
+		lines[i] = strings.TrimPrefix(line, indent)
+	}
+	return lines
+}
+
+// formatBody indents the body if it is a valid JSON sent as JSON.
+func formatBody(r *Request) string {
+	for _, h := range r.Headers {
+		if !strings.EqualFold(h.Name, "Content-Type") || !parseContentType(h.Value).isJSON() {
+			continue
+		}
+		var out bytes.Buffer
+		if err := json.Indent(&out, []byte(r.Body), "", "  "); err == nil {
+			return out.String()
+		}
+	}
+	return r.Body
+}
@@ -0,0 +1,263 @@
+package gpc
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFormat(t *testing.T) {
+	tests := []struct {
+		name string
+		src  string
+		want string
+	}{
+		{
+			name: "separators and headers",
+			src: `GET example.com/a
+accept:application/json
+###   second request
+// comment after separator
+  POST example.com/b
+x-trace-id : 42
+
+
+
+plain body
+###
+DELETE example.com/c`,
+			want: `GET example.com/a
+Accept: application/json
+
+### second request
+// comment after separator
+POST example.com/b
+X-Trace-Id: 42
+
+plain body
+
+###
+DELETE example.com/c
+`,
+		},
+		{
+			name: "embedded scripts",
+			src: `### scripts
+< {% request.variables.set("id", "42") %}
+GET example.com/{{id}}
+> {%
+        client.test("ok", function() {
+            client.assert(response.status === 200)
+        })
+
+
+%}
+`,
+			want: `### scripts
+< {%
+    request.variables.set("id", "42")
+%}
+GET example.com/{{id}}
+
+> {%
+    client.test("ok", function() {
+        client.assert(response.status === 200)
+    })
+%}
+`,
+		},
+		{
+			name: "JSON body",
+			src: `POST example.com
+Content-Type: application/json; charset=utf-8
+
+{"name":"joe","tags":["a","b"]}
+
+> check.js`,
+			want: `POST example.com
+Content-Type: application/json; charset=utf-8
+
+{
+  "name": "joe",
+  "tags": [
+    "a",
+    "b"
+  ]
+}
+
+> check.js
+`,
+		},
+		{
+			name: "JSON body with variables is kept",
+			src: `POST example.com
+Content-Type: application/json
+
+{"id": {{id}}}
+`,
+			want: `POST example.com
+Content-Type: application/json
+
+{"id": {{id}}}
+`,
+		},
+		{
+			name: "comments",
+			src: `# Users API
+### create user
+# @name createUser
+POST example.com/users
+# comment between headers
+Accept: application/json
+
+< ./user.json
+// after body
+> {% client.global.set("user", response.body.id) %}
+# at the end
+`,
+			want: `# Users API
+
+### create user
+# @name createUser
+POST example.com/users
+# comment between headers
+Accept: application/json
+
+< ./user.json
+// after body
+
+> {%
+    client.global.set("user", response.body.id)
+%}
+# at the end
+`,
+		},
+		{
+			name: "HTTP version",
+			src:  "GET http://x   HTTP/1.1\nAccept: */*\n",
+			want: "GET http://x HTTP/1.1\nAccept: */*\n",
+		},
+		{
+			name: "XML body",
+			src: `POST http://x
+Content-Type: application/xml
+
+
+<note><to>a</to></note>
+`,
+			want: `POST http://x
+Content-Type: application/xml
+
+<note><to>a</to></note>
+`,
+		},
+		{
+			name: "commented out request",
+			src:  "### Disabled\n# GET http://x/old\n\n### Next\nGET http://y",
+			want: "### Disabled\n# GET http://x/old\n\n### Next\nGET http://y\n",
+		},
+		{
+			name: "separator without request",
+			src:  "### A\n### B\nGET http://y\n",
+			want: "### A\n\n### B\nGET http://y\n",
+		},
+		{
+			name: "separator at the end",
+			src:  "GET http://y\n###   Later\n# TODO\n",
+			want: "GET http://y\n\n### Later\n# TODO\n",
+		},
+		{
+			name: "only comments",
+			src:  "# nothing to call\n",
+			want: "# nothing to call\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Format([]byte(tt.src))
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, string(got))
+
+			again, err := Format(got)
+			require.NoError(t, err)
+			assert.Equal(t, string(got), string(again), "formatting is not stable")
+		})
+	}
+}
+
+func TestFormatPreservesSteps(t *testing.T) {
+	src := `### create
+# @name created
+< {%
+  request.variables.set("id", "42")
+%}
+PUT example.com/{{id}} HTTP/1.1
+Content-Type: application/json
+
+{"a": 1}
+
+> {% client.test("ok", function() {}) %}
+
+### read
+GET example.com/{{created.response.body.$.id}}
+`
+	got, err := Format([]byte(src))
+	require.NoError(t, err)
+	before, err := Parse(strings.NewReader(src))
+	require.NoError(t, err)
+	after, err := Parse(strings.NewReader(string(got)))
+	require.NoError(t, err)
+	require.Len(t, after.Requests, len(before.Requests))
+	for i := range before.Requests {
+		assert.Equal(t, before.Requests[i].Name, after.Requests[i].Name)
+		assert.Equal(t, before.Requests[i].Metadata, after.Requests[i].Metadata)
+		assert.Equal(t, before.Requests[i].Method, after.Requests[i].Method)
+		assert.Equal(t, before.Requests[i].URL, after.Requests[i].URL)
+		assert.Equal(t, before.Requests[i].HTTPVersion, after.Requests[i].HTTPVersion)
+		assert.Equal(t, before.Requests[i].PreRequestHandler != nil, after.Requests[i].PreRequestHandler != nil)
+		assert.Equal(t, before.Requests[i].ResponseHandler != nil, after.Requests[i].ResponseHandler != nil)
+	}
+}
+
+func TestFormatErrors(t *testing.T) {
+	_, err := Format([]byte("get example.com\n"))
+	var diags Diagnostics
+	assert.ErrorAs(t, err, &diags)
+
+	_, err = Format([]byte("GET example.com\n\n###\n< {% request.variables.set(\"a\", 1) %}\n"))
+	assert.EqualError(t, err, "4:5: pre-request handler without request")
+}
+
+func TestFormatSamples(t *testing.T) {
+	files, err := filepath.Glob("../samples/*/*.http")
+	require.NoError(t, err)
+	for _, file := range files {
+		src, err := os.ReadFile(file)
+		require.NoError(t, err)
+		got, err := Format(src)
+		require.NoError(t, err, file)
+		assert.Equal(t, string(src), string(got), "%s is not formatted", file)
+	}
+}
+
+func TestFprint(t *testing.T) {
+	// Generated files have no positions.
+	f := &File{Requests: []*Request{{
+		Name:     "generated",
+		Comments: []Comment{{Text: "# @name generated"}},
+		Method:   "GET",
+		URL:      "example.com",
+		Headers:  []Header{{Name: "accept", Value: "*/*"}},
+	}}}
+	var sb strings.Builder
+	require.NoError(t, Fprint(&sb, f))
+	assert.Equal(t, `### generated
+# @name generated
+GET example.com
+Accept: */*
+`, sb.String())
+}
@@ -1,8 +1,6 @@
 package gpc
 
 import (
-	"errors"
-	"fmt"
 	"io"
 	"strings"
 )
@@ -10,82 +8,224 @@ import (
 type script struct {
 	file    string
 	content string
+	pos     position // Position of the content of embedded script or of the script file path.
+}
+
+type header struct {
+	name  string
+	value string
+	pos   position
+}
+
+type comment struct {
+	text string
+	pos  position
 }
 
 type step struct {
-	name            string
-	method          string
-	url             string
-	responseHandler *script
+	name              string
+	separatorPos      position          // Position after ###, zero when the step has no separator.
+	comments          []comment         // Comment lines in order of appearance.
+	pos               position          // Position of the request line.
+	metadata          map[string]string // Values of # @key value comments, i.e. @name.
+	method            string
+	url               string
+	httpVersion       string // Text after the URL on the request line, kept for formatting only.
+	headers           []header
+	body              string
+	bodyFile          string   // Path to the file with the body, relative to .http file.
+	bodyPos           position // Position of either body or body file.
+	preRequestHandler *script
+	responseHandler   *script
 }
 
 func (s step) valid() bool {
 	return s.method != "" || s.url != ""
 }
 
+// keepSeparator turns the separator of a step without request into a comment, so the
+// separator and the commented out request after it stay in the file when it is formatted.
+func (s *step) keepSeparator() {
+	if s.separatorPos.line == 0 {
+		return
+	}
+	c := comment{
+		text: strings.TrimSpace(requestSeparator + " " + s.name),
+		pos:  position{line: s.separatorPos.line, col: s.separatorPos.col - len(requestSeparator)},
+	}
+	// Comments before the separator stay before it.
+	at := 0
+	for at < len(s.comments) && s.comments[at].pos.line < c.pos.line {
+		at++
+	}
+	s.comments = append(s.comments[:at], append([]comment{c}, s.comments[at:]...)...)
+	s.name = ""
+	s.separatorPos = position{}
+}
+
+// displayName returns the name of the step from the request separator or the request line.
+func (s step) displayName() string {
+	if s.name != "" {
+		return s.name
+	}
+	return s.method + " " + s.url
+}
+
+// requestName returns the name used to refer to the request from other requests.
+func (s step) requestName() string {
+	return s.metadata[metadataName]
+}
+
+const metadataName = "name"
+
+// parseMetadata extracts key and value of # @key value comment.
+func parseMetadata(comment string) (key string, value string, ok bool) {
+	for _, c := range commentStarts {
+		comment = strings.TrimPrefix(comment, c)
+	}
+	comment = strings.TrimSpace(comment)
+	if !strings.HasPrefix(comment, "@") {
+		return "", "", false
+	}
+	key, value, _ = strings.Cut(strings.TrimPrefix(comment, "@"), " ")
+	key = strings.TrimSpace(key)
+	if key == "" {
+		return "", "", false
+	}
+	return key, strings.TrimSpace(value), true
+}
+
+// makeRecipe parses the steps. All problems found in the input are returned as Diagnostics.
 func makeRecipe(reader io.Reader) ([]step, error) {
 	s := newScanner(reader)
-	s.scan()
-	for _, item := range s.items {
-		if item.tok == tokenError {
-			return nil, fmt.Errorf("failed to make steps: %v", item.val)
-		}
+	if err := s.scan(); err != nil {
+		return nil, err
 	}
+	var diags Diagnostics
 	res := []step{}
 	currentStep := step{}
 	var currentHandler *script = nil
 	for _, item := range s.items {
 		switch item.tok {
+		case tokenError:
+			diags.add(item, "%s", errorMessage(item.val))
 		case tokenRequestSeparator:
-			currentStep.name = item.val
 			if currentStep.valid() {
 				res = append(res, currentStep)
 				currentStep = step{}
 				currentHandler = nil
 			}
+			currentStep.keepSeparator()
+			currentStep.name = item.val
+			currentStep.separatorPos = item.pos
+		case tokenComment:
+			currentStep.comments = append(currentStep.comments, comment{text: item.val, pos: item.pos})
+			if key, value, ok := parseMetadata(item.val); ok {
+				if currentStep.metadata == nil {
+					currentStep.metadata = make(map[string]string)
+				}
+				currentStep.metadata[key] = value
+			}
 		case tokenVerb:
 			if currentStep.method != "" {
-				return nil, errors.New("request separator is missing (verb)")
-			} else {
-				currentStep.method = item.val
+				diags.add(item, "request separator is missing (verb)")
+				continue
 			}
+			currentStep.method = item.val
+			currentStep.pos = item.pos
 		case tokenURL:
 			if currentStep.method == "" {
-				return nil, errors.New("method is missing")
+				diags.add(item, "method is missing")
+				continue
 			}
 			if currentStep.url != "" {
-				return nil, errors.New("request separator is missing (url)")
-			} else {
-				currentStep.url = item.val
+				diags.add(item, "request separator is missing (url)")
+				continue
+			}
+			currentStep.url = item.val
+		case tokenHTTPVersion:
+			if currentStep.url == "" {
+				diags.add(item, "HTTP version without request")
+				continue
+			}
+			currentStep.httpVersion = item.val
+		case tokenHeader:
+			if currentStep.url == "" {
+				diags.add(item, "header without request")
+				continue
+			}
+			name, value, _ := strings.Cut(item.val, ":")
+			currentStep.headers = append(currentStep.headers, header{
+				name:  strings.TrimSpace(name),
+				value: strings.TrimSpace(value),
+				pos:   item.pos,
+			})
+		case tokenBody:
+			if currentStep.url == "" {
+				diags.add(item, "body without request")
+				continue
 			}
+			currentStep.body = item.val
+			currentStep.bodyPos = item.pos
+		case tokenBodyFile:
+			if currentStep.url == "" {
+				diags.add(item, "body file without request")
+				continue
+			}
+			currentStep.bodyFile = item.val
+			currentStep.bodyPos = item.pos
+		case tokenPreRequestHandler:
+			if currentStep.valid() {
+				diags.add(item, "pre-request handler has to precede the request")
+				continue
+			}
+			currentStep.preRequestHandler = &script{}
+			currentHandler = currentStep.preRequestHandler
 		case tokenResponseHandler:
 			if !currentStep.valid() {
-				return nil, errors.New("failed to declare response handler for invalid request")
+				diags.add(item, "failed to declare response handler for invalid request")
+				continue
 			}
 			currentStep.responseHandler = &script{}
 			currentHandler = currentStep.responseHandler
 		case tokenScriptFile:
 			if currentHandler == nil {
-				return nil, errors.New("missing handler context")
+				diags.add(item, "missing handler context")
+				continue
 			}
-			// TODO: is it a good time to check file?
 			currentHandler.file = item.val
+			currentHandler.pos = item.pos
 		case tokenEmbeddedScript:
 			if currentHandler == nil {
-				return nil, errors.New("missing handler context")
+				diags.add(item, "missing handler context")
+				continue
 			}
 			if !strings.HasPrefix(item.val, scriptStart) || !strings.HasSuffix(item.val, scriptEnd) {
-				return nil, errors.New("invalid script")
+				diags.add(item, "invalid script")
+				continue
 			}
 			currentHandler.content = strings.TrimSuffix(strings.TrimPrefix(item.val, scriptStart), scriptEnd)
+			currentHandler.pos = position{line: item.pos.line, col: item.pos.col + len(scriptStart)}
 		default:
-			return nil, fmt.Errorf("unexpected token: %v - %v", item.tok, item.val)
+			diags.add(item, "unexpected token: %v - %v", item.tok, item.val)
 		}
 	}
 	if currentStep.valid() {
 		res = append(res, currentStep)
-		currentStep = step{}
-		currentHandler = nil
+	} else if currentStep.preRequestHandler != nil {
+		diags = append(diags, Diagnostic{
+			Line:    currentStep.preRequestHandler.pos.line,
+			Column:  currentStep.preRequestHandler.pos.col,
+			Message: "pre-request handler without request",
+		})
+	} else if len(res) > 0 {
+		// Separators and comments at the end of the file stay with the last request.
+		currentStep.keepSeparator()
+		last := &res[len(res)-1]
+		last.comments = append(last.comments, currentStep.comments...)
+	}
+	if len(diags) > 0 {
+		return nil, diags
 	}
 	return res, nil
 }
@@ -16,9 +16,11 @@ GET example.com`)
 		assert.NoError(t, err)
 		require.Len(t, steps, 1)
 		assert.Equal(t, step{
-			name:   "call example.com",
-			method: "GET",
-			url:    "example.com",
+			name:         "call example.com",
+			separatorPos: position{line: 1, col: 4},
+			method:       "GET",
+			pos:          position{line: 2, col: 1},
+			url:          "example.com",
 		}, steps[0])
 	})
 
@@ -33,14 +35,157 @@ console.log("Hello")
 		assert.NoError(t, err)
 		require.Len(t, steps, 1)
 		assert.Equal(t, step{
-			name:   "call example.com",
-			method: "GET",
-			url:    "example.com",
+			name:         "call example.com",
+			separatorPos: position{line: 1, col: 4},
+			method:       "GET",
+			pos:          position{line: 2, col: 1},
+			url:          "example.com",
 			responseHandler: &script{
 				content: `
 console.log("Hello")
 `,
+				pos: position{line: 4, col: 5},
+			},
+		}, steps[0])
+	})
+	t.Run("separator without request", func(t *testing.T) {
+		r := strings.NewReader(`### disabled
+# GET example.com/old
+
+### next
+GET example.com`)
+		steps, err := makeRecipe(r)
+		assert.NoError(t, err)
+		require.Len(t, steps, 1)
+		assert.Equal(t, step{
+			name:         "next",
+			separatorPos: position{line: 4, col: 4},
+			comments: []comment{
+				{text: "### disabled", pos: position{line: 1, col: 1}},
+				{text: "# GET example.com/old", pos: position{line: 2, col: 1}},
+			},
+			method: "GET",
+			pos:    position{line: 5, col: 1},
+			url:    "example.com",
+		}, steps[0])
+	})
+	t.Run("GET with headers", func(t *testing.T) {
+		r := strings.NewReader(`### call example.com
+GET example.com
+Accept: application/json
+X-Trace-Id : 42
+`)
+		steps, err := makeRecipe(r)
+		assert.NoError(t, err)
+		require.Len(t, steps, 1)
+		assert.Equal(t, step{
+			name:         "call example.com",
+			separatorPos: position{line: 1, col: 4},
+			method:       "GET",
+			pos:          position{line: 2, col: 1},
+			url:          "example.com",
+			headers: []header{
+				{name: "Accept", value: "application/json", pos: position{line: 3, col: 1}},
+				{name: "X-Trace-Id", value: "42", pos: position{line: 4, col: 1}},
 			},
 		}, steps[0])
 	})
+	t.Run("POST with body", func(t *testing.T) {
+		r := strings.NewReader(`### create
+POST example.com
+Content-Type: text/plain
+
+first line
+
+second line
+> {%
+console.log("Hello")
+%}`)
+		steps, err := makeRecipe(r)
+		assert.NoError(t, err)
+		require.Len(t, steps, 1)
+		assert.Equal(t, "first line\n\nsecond line", steps[0].body)
+		assert.NotNil(t, steps[0].responseHandler)
+	})
+	t.Run("named requests", func(t *testing.T) {
+		r := strings.NewReader(`### create user
+# @name createUser
+// just a comment
+POST example.com/users
+
+###
+GET example.com/users/{{createUser.response.body.$.id}}
+`)
+		steps, err := makeRecipe(r)
+		assert.NoError(t, err)
+		require.Len(t, steps, 2)
+		assert.Equal(t, step{
+			name:         "create user",
+			separatorPos: position{line: 1, col: 4},
+			comments: []comment{
+				{text: "# @name createUser", pos: position{line: 2, col: 1}},
+				{text: "// just a comment", pos: position{line: 3, col: 1}},
+			},
+			metadata: map[string]string{"name": "createUser"},
+			method:   "POST",
+			pos:      position{line: 4, col: 1},
+			url:      "example.com/users",
+		}, steps[0])
+		assert.Equal(t, "createUser", steps[0].requestName())
+		assert.Equal(t, step{
+			separatorPos: position{line: 6, col: 4},
+			method:       "GET",
+			pos:          position{line: 7, col: 1},
+			url:          "example.com/users/{{createUser.response.body.$.id}}",
+		}, steps[1])
+	})
+	t.Run("pre-request handler", func(t *testing.T) {
+		r := strings.NewReader(`### call example.com
+< {%
+request.variables.set("id", "42")
+%}
+GET example.com/{{id}}`)
+		steps, err := makeRecipe(r)
+		assert.NoError(t, err)
+		require.Len(t, steps, 1)
+		require.NotNil(t, steps[0].preRequestHandler)
+		assert.Equal(t, `
+request.variables.set("id", "42")
+`, steps[0].preRequestHandler.content)
+		assert.Nil(t, steps[0].responseHandler)
+	})
+
+	t.Run("pre-request handler after request", func(t *testing.T) {
+		r := strings.NewReader(`GET example.com
+< {% console.log("late") %}`)
+		_, err := makeRecipe(r)
+		assert.Error(t, err)
+	})
+	t.Run("all problems are reported", func(t *testing.T) {
+		r := strings.NewReader(`### first
+Get example.com
+GET example.com
+not a header
+
+### second
+GET example.com
+GET example.com
+
+> {% console.log("never ends")
+`)
+		_, err := makeRecipe(r)
+		var diags Diagnostics
+		require.ErrorAs(t, err, &diags)
+		assert.Equal(t, Diagnostics{
+			{Line: 2, Column: 1, Message: `unexpected "Get"`},
+			{Line: 4, Column: 1, Message: `unexpected "not a header"`},
+			{Line: 8, Column: 1, Message: `unexpected "GET example.com"`},
+			{Line: 10, Column: 3, Message: "unterminated script, %} is missing"},
+		}, diags)
+	})
+	t.Run("body file without path", func(t *testing.T) {
+		r := strings.NewReader("POST example.com\n\n<\n")
+		_, err := makeRecipe(r)
+		assert.EqualError(t, err, "3:1: body file path is missing")
+	})
 }
@@ -0,0 +1,67 @@
+package gpc
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+)
+
+// Response references look like {{createUser.response.body.$.id}} or {{createUser.response.headers.Location}},
+// where createUser is declared with # @name createUser.
+const (
+	referenceResponse = ".response."
+	referenceBody     = "body"
+	referenceHeaders  = "headers."
+)
+
+// lookupReference resolves reference to the response of already executed named request.
+func (r Report) lookupReference(ref string) (string, bool) {
+	name, path, ok := strings.Cut(ref, referenceResponse)
+	if !ok {
+		return "", false
+	}
+	var found *StepResult
+	for i := range r.steps {
+		if r.steps[i].step.requestName() == name && r.steps[i].res != nil {
+			found = &r.steps[i]
+		}
+	}
+	if found == nil {
+		return "", false
+	}
+
+	if h, ok := strings.CutPrefix(path, referenceHeaders); ok {
+		values := found.res.Header.Values(h)
+		if len(values) == 0 {
+			return "", false
+		}
+		return strings.Join(values, ", "), true
+	}
+
+	jsonPath, ok := strings.CutPrefix(path, referenceBody)
+	if !ok {
+		return "", false
+	}
+	if jsonPath == "" {
+		return string(found.resBody), true
+	}
+	jsonPath, ok = strings.CutPrefix(jsonPath, ".")
+	if !ok {
+		return "", false
+	}
+	d := json.NewDecoder(bytes.NewReader(found.resBody))
+	d.UseNumber()
+	var doc any
+	if err := d.Decode(&doc); err != nil {
+		return "", false
+	}
+	v, err := evalJSONPath(jsonPath, doc)
+	if err != nil {
+		return "", false
+	}
+	s, err := jsonValueString(v)
+	if err != nil {
+		return "", false
+	}
+	return s, true
+}
@@ -0,0 +1,128 @@
+package gpc
+
+import "strings"
+
+// RequestAdapter is the request object available to pre-request handlers.
+type RequestAdapter struct {
+	Method      string              `json:"method"`
+	URL         *requestText        `json:"url"`
+	Body        *requestText        `json:"body"`
+	Headers     *requestHeaders     `json:"headers"`
+	Variables   *requestVariables   `json:"variables"`
+	Environment *requestEnvironment `json:"environment"`
+}
+
+// newRequestAdapter exposes the step to the script. Substitutions use lookup, so variables set by the script
+// are visible immediately.
+func newRequestAdapter(s step, variables *requestVariables, environment map[string]string, lookup lookupFn) *RequestAdapter {
+	r := &RequestAdapter{
+		Method:      s.method,
+		URL:         &requestText{raw: s.url, lookup: lookup},
+		Body:        &requestText{raw: s.body, lookup: lookup},
+		Headers:     &requestHeaders{},
+		Variables:   variables,
+		Environment: &requestEnvironment{values: environment},
+	}
+	for _, h := range s.headers {
+		r.Headers.headers = append(r.Headers.headers, &requestHeader{
+			Name:  h.name,
+			value: requestText{raw: h.value, lookup: lookup},
+		})
+	}
+	return r
+}
+
+// requestText is a part of the request that might contain {{name}} placeholders.
+type requestText struct {
+	raw    string
+	lookup lookupFn
+}
+
+// GetRaw is getRaw(), returns the text as it is in .http file.
+func (t *requestText) GetRaw() string {
+	return t.raw
+}
+
+// TryGetSubstituted is tryGetSubstituted(), returns the text with known variables replaced.
+func (t *requestText) TryGetSubstituted() string {
+	s := substitution{lookup: t.lookup}
+	return s.expand(t.raw)
+}
+
+type requestHeader struct {
+	Name  string `json:"name"`
+	value requestText
+}
+
+// GetRawValue is getRawValue()
+func (h *requestHeader) GetRawValue() string {
+	return h.value.GetRaw()
+}
+
+// TryGetSubstitutedValue is tryGetSubstitutedValue()
+func (h *requestHeader) TryGetSubstitutedValue() string {
+	return h.value.TryGetSubstituted()
+}
+
+type requestHeaders struct {
+	headers []*requestHeader
+}
+
+// All is request.headers.all()
+func (h *requestHeaders) All() []*requestHeader {
+	return h.headers
+}
+
+// FindByName is request.headers.findByName(name), returns null if there is no such header.
+func (h *requestHeaders) FindByName(name string) *requestHeader {
+	for _, header := range h.headers {
+		if strings.EqualFold(header.Name, name) {
+			return header
+		}
+	}
+	return nil
+}
+
+// requestVariables is request.variables, values are visible only for the current request.
+type requestVariables struct {
+	values map[string]string
+}
+
+func newRequestVariables() *requestVariables {
+	return &requestVariables{
+		values: make(map[string]string),
+	}
+}
+
+// Set is request.variables.set(name, value)
+func (v *requestVariables) Set(name string, value string) {
+	v.values[name] = value
+}
+
+// Get is request.variables.get(name), returns null when the variable is not set.
+func (v *requestVariables) Get(name string) any {
+	val, ok := v.values[name]
+	if !ok {
+		return nil
+	}
+	return val
+}
+
+func (v *requestVariables) lookup(name string) (string, bool) {
+	val, ok := v.values[name]
+	return val, ok
+}
+
+// requestEnvironment is request.environment, read-only values of the active environment.
+type requestEnvironment struct {
+	values map[string]string
+}
+
+// Get is request.environment.get(name), returns null when the variable is not defined.
+func (e *requestEnvironment) Get(name string) any {
+	val, ok := e.values[name]
+	if !ok {
+		return nil
+	}
+	return val
+}
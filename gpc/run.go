@@ -0,0 +1,193 @@
+package gpc
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/strotz/goplaycalls/pipes"
+)
+
+// Option configures the Player used by Run.
+type Option func(c *runConfig)
+
+type runConfig struct {
+	dialer          pipes.DialerFunc
+	handler         http.Handler
+	env             string
+	variables       map[string]string
+	baseDir         string
+	filter          func(name string) bool
+	continueOnError bool
+	observer        func(Event)
+	ctx             context.Context
+}
+
+// WithDialer sends requests through the dialer, i.e. pipes.CreateDialer to reach the test server.
+func WithDialer(dialer pipes.DialerFunc) Option {
+	return func(c *runConfig) {
+		c.dialer = dialer
+	}
+}
+
+// WithHandler serves requests in-process with the handler, no listener or socket is needed.
+func WithHandler(handler http.Handler) Option {
+	return func(c *runConfig) {
+		c.handler = handler
+	}
+}
+
+// WithEnvironment activates the environment from http-client.env.json files.
+func WithEnvironment(name string) Option {
+	return func(c *runConfig) {
+		c.env = name
+	}
+}
+
+// WithVariables sets values of {{name}} placeholders, they take precedence over the environment.
+func WithVariables(variables map[string]string) Option {
+	return func(c *runConfig) {
+		if c.variables == nil {
+			c.variables = make(map[string]string)
+		}
+		for k, v := range variables {
+			c.variables[k] = v
+		}
+	}
+}
+
+// WithBaseDir overrides the directory used to find environment, body and script files.
+func WithBaseDir(dir string) Option {
+	return func(c *runConfig) {
+		c.baseDir = dir
+	}
+}
+
+// WithStepFilter plays only the requests which names are accepted by the filter.
+func WithStepFilter(filter func(name string) bool) Option {
+	return func(c *runConfig) {
+		c.filter = filter
+	}
+}
+
+// WithContinueOnError plays all requests even if some of them fail.
+func WithContinueOnError() Option {
+	return func(c *runConfig) {
+		c.continueOnError = true
+	}
+}
+
+// WithObserver passes the events of the play to the observer, i.e. NDJSONWriter.Event.
+func WithObserver(observer func(Event)) Option {
+	return func(c *runConfig) {
+		c.observer = observer
+	}
+}
+
+// WithContext plays the requests with the context, the play stops when it is done.
+func WithContext(ctx context.Context) Option {
+	return func(c *runConfig) {
+		c.ctx = ctx
+	}
+}
+
+// RunTests plays the file as a sequence of subtests, one per request, named after the request separator.
+// Each client.test of the response handler is reported as a nested subtest.
+func RunTests(filePath string, t *testing.T) Report {
+	return Run(t, filePath)
+}
+
+// RunTestsInEnvironment plays the file with the named environment active, no environment is used when env is empty.
+func RunTestsInEnvironment(filePath string, env string, t *testing.T) Report {
+	return Run(t, filePath, WithEnvironment(env))
+}
+
+// Run plays the file and reports failures to tb. When tb is *testing.T, requests and their tests are
+// reported as subtests like in RunTests, otherwise (i.e. benchmarks) failures are reported as errors.
+// Requests that are not played because a failure stopped the play are reported as skipped subtests.
+func Run(tb testing.TB, filePath string, opts ...Option) Report {
+	tb.Helper()
+	c := runConfig{}
+	for _, opt := range opts {
+		opt(&c)
+	}
+
+	p, err := ParseFile(filePath)
+	if err != nil {
+		tb.Fatal(err)
+	}
+	if c.baseDir != "" {
+		p.baseDir = c.baseDir
+	}
+	p.Dialer = c.dialer
+	p.Handler = c.handler
+	p.ContinueOnError = c.continueOnError
+	p.Observer = c.observer
+	if c.env != "" {
+		if err := p.UseEnvironment(c.env); err != nil {
+			tb.Fatal(err)
+		}
+	}
+	for k, v := range c.variables {
+		p.SetVariable(k, v)
+	}
+
+	ctx := c.ctx
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	// The error that stopped the play is reported with its step.
+	_ = p.play(ctx, func(step step, play func() StepResult) {
+		// Steps rejected by the filter are not played and don't stop the following ones.
+		if c.filter != nil && !c.filter(step.displayName()) {
+			return
+		}
+		t, isT := tb.(*testing.T)
+		if !isT {
+			if play != nil {
+				p.reportStep(tb, play())
+			}
+			return
+		}
+		// Steps excluded by -run are not played either, t.Run doesn't call the function for them.
+		t.Run(step.displayName(), func(t *testing.T) {
+			if play == nil {
+				// Following steps might depend on the failed one, so they are not played.
+				t.Skip("not played, the play is stopped")
+			}
+			p.reportStep(t, play())
+		})
+	})
+	return p.report
+}
+
+// reportStep reports the failure and the tests of the played step to tb.
+func (p *Player) reportStep(tb testing.TB, item StepResult) {
+	tb.Helper()
+	if item.err != nil && item.err.Kind != AssertionFailure {
+		// Failed assertions are reported per test below.
+		tb.Errorf("%s: %v", item.err.Kind, item.err)
+	}
+	for _, result := range item.TestResults() {
+		report := func(tb testing.TB) {
+			if result.Passed {
+				return
+			}
+			if loc := result.Location(); loc != "" {
+				tb.Errorf("%s: %s\n%s", loc, result.Message, result.Stack)
+			} else {
+				tb.Errorf("failure:\n%s", result.Stack)
+			}
+		}
+		if t, isT := tb.(*testing.T); isT {
+			t.Run(result.Name, func(t *testing.T) {
+				report(t)
+			})
+		} else {
+			report(tb)
+		}
+	}
+	if item.Failed() {
+		tb.Logf("test console:\n%s", item.ResponseHandlerOutput())
+	}
+}
@@ -0,0 +1,165 @@
+package gpc
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/strotz/goplaycalls/pipes"
+	"github.com/strotz/goplaycalls/testserver"
+)
+
+func TestRunTests(t *testing.T) {
+	sm := http.NewServeMux()
+	sm.HandleFunc("GET /a", echoHandler)
+	sm.HandleFunc("PUT /b", echoHandler)
+	s := httptest.NewServer(sm)
+	t.Cleanup(s.Close)
+
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, publicEnvFile), []byte(`{"test": {"host": "`+s.URL+`"}}`), 0644))
+	recipe := filepath.Join(dir, "run.http")
+	require.NoError(t, os.WriteFile(recipe, []byte(`### First call
+GET {{host}}/a
+
+> {%
+client.test("status", function() {
+	client.assert(response.status === 200, "unexpected status");
+});
+%}
+
+###
+PUT {{host}}/b
+`), 0644))
+
+	var names []string
+	r := RunTestsInEnvironment(recipe, "test", t)
+	for _, step := range r.Steps() {
+		names = append(names, step.step.displayName())
+	}
+	assert.Equal(t, []string{"First call", "PUT {{host}}/b"}, names)
+	require.Len(t, r.Steps()[0].TestResults(), 1)
+	assert.True(t, r.Steps()[0].TestResults()[0].Passed)
+}
+
+func TestRunWithOptions(t *testing.T) {
+	pipeName := t.Name()
+	sm := http.NewServeMux()
+	sm.HandleFunc("GET /a", echoHandler)
+	ts := testserver.NewHandlerTestServer(pipeName, sm)
+	ts.Start()
+	t.Cleanup(ts.Stop)
+
+	dir := t.TempDir()
+	recipe := filepath.Join(dir, "run.http")
+	require.NoError(t, os.WriteFile(recipe, []byte(`### Reachable
+GET {{host}}/a
+
+> check.js
+
+### Skipped
+GET {{host}}/missing
+
+> check.js
+`), 0644))
+	scripts := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(scripts, "check.js"), []byte(`
+client.test("status", function() {
+	client.assert(response.status === 200, "unexpected status");
+});
+`), 0644))
+
+	r := Run(t, recipe,
+		WithDialer(pipes.CreateDialer(pipeName)),
+		WithVariables(map[string]string{"host": "http://localhost:8080"}),
+		WithBaseDir(scripts),
+		WithStepFilter(func(name string) bool {
+			return !strings.HasPrefix(name, "Skipped")
+		}),
+	)
+	require.Len(t, r.Steps(), 1)
+	assert.False(t, r.TestFailed())
+}
+
+// TestRunHelper plays the recipe of the tests below in a child process, so they can check failed and
+// skipped subtests in its output.
+func TestRunHelper(t *testing.T) {
+	recipe := os.Getenv("GPC_RUN_RECIPE")
+	if recipe == "" {
+		t.Skip("played by other tests")
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	if os.Getenv("GPC_RUN_CANCELLED") != "" {
+		cancel()
+	}
+	r := Run(t, recipe, WithContext(ctx))
+	var names []string
+	for _, step := range r.Steps() {
+		names = append(names, step.Name())
+	}
+	t.Logf("played: %s", strings.Join(names, ","))
+}
+
+// runHelper runs TestRunHelper with the recipe and returns its verbose output.
+func runHelper(recipe string, run string, env ...string) (string, error) {
+	cmd := exec.Command(os.Args[0], "-test.run", run, "-test.v")
+	cmd.Env = append(os.Environ(), "GPC_RUN_RECIPE="+recipe)
+	cmd.Env = append(cmd.Env, env...)
+	out, err := cmd.CombinedOutput()
+	return string(out), err
+}
+
+// writeTwoSteps writes the recipe with two requests to the server.
+func writeTwoSteps(t *testing.T, url string) string {
+	recipe := filepath.Join(t.TempDir(), "steps.http")
+	require.NoError(t, os.WriteFile(recipe, []byte(`### first
+GET `+url+`/a
+
+### second
+GET `+url+`/b
+`), 0644))
+	return recipe
+}
+
+func TestRunFiltered(t *testing.T) {
+	sm := http.NewServeMux()
+	sm.HandleFunc("GET /a", echoHandler)
+	sm.HandleFunc("GET /b", echoHandler)
+	s := httptest.NewServer(sm)
+	t.Cleanup(s.Close)
+
+	out, err := runHelper(writeTwoSteps(t, s.URL), "^TestRunHelper$/^second$")
+	require.NoError(t, err, out)
+	assert.Contains(t, out, "played: second\n")
+}
+
+func TestRunStopped(t *testing.T) {
+	s := httptest.NewServer(http.HandlerFunc(echoHandler))
+	s.Close()
+
+	out, err := runHelper(writeTwoSteps(t, s.URL), "^TestRunHelper$")
+	require.Error(t, err, out)
+	assert.Contains(t, out, "--- FAIL: TestRunHelper/first")
+	assert.Contains(t, out, "--- SKIP: TestRunHelper/second")
+	assert.Contains(t, out, "played: first\n")
+}
+
+func TestRunWithContext(t *testing.T) {
+	s := httptest.NewServer(http.HandlerFunc(echoHandler))
+	t.Cleanup(s.Close)
+
+	out, err := runHelper(writeTwoSteps(t, s.URL), "^TestRunHelper$", "GPC_RUN_CANCELLED=1")
+	require.Error(t, err, out)
+	assert.Contains(t, out, "context canceled")
+	assert.Contains(t, out, "--- SKIP: TestRunHelper/second")
+	assert.Contains(t, out, "played: first\n")
+}
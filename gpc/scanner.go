@@ -4,8 +4,6 @@ import (
 	"bufio"
 	"fmt"
 	"io"
-	"log"
-	"net/http"
 	"strings"
 )
 
@@ -13,8 +11,12 @@ import (
 
 // Ignore
 // RequestSeparator Comment
-// Verb URL
-// <empty line?>
+// # comment or // comment, i.e. # @name metadata
+// < {% .... %}
+// Verb URL [HTTP version]
+// Header-Name: value
+// <empty line>
+// Body or < path/to/body
 // > {% .... %}
 // > file.js
 
@@ -29,9 +31,15 @@ const (
 	tokenError token = iota
 
 	tokenRequestSeparator
+	tokenComment
 	tokenVerb
 	tokenURL
+	tokenHTTPVersion
+	tokenHeader
+	tokenBody
+	tokenBodyFile
 
+	tokenPreRequestHandler
 	tokenResponseHandler
 
 	tokenEmbeddedScript
@@ -40,10 +48,25 @@ const (
 
 const requestSeparator = "###"
 const responseHandlerStart = ">"
+const bodyFileStart = "<"
+const preRequestHandlerStart = "<"
+
+var commentStarts = []string{"#", "//"}
+
+// position in the input, line and column are 1-based. Column counts runes.
+type position struct {
+	line int
+	col  int
+}
+
+func (p position) String() string {
+	return fmt.Sprintf("%d:%d", p.line, p.col)
+}
 
 type item struct {
 	tok token
 	val string
+	pos position // Position of the first character of the value.
 }
 
 func (i item) String() string {
@@ -61,6 +84,12 @@ type scanner struct {
 	reader       *bufio.Reader
 	items        []item
 	currentValue strings.Builder
+
+	pos   position // Position of the next rune.
+	prev  position // Position before the last read, used by unread.
+	start position // Position of the first rune in currentValue.
+
+	err error // Error of the reader, the input is treated as ended after it.
 }
 
 // stateFn scans input while tracking the lexer state and returns state function that tracks the next state.
@@ -70,22 +99,37 @@ type stateFn func(scanner *scanner) stateFn
 func newScanner(reader io.Reader) *scanner {
 	return &scanner{
 		reader: bufio.NewReader(reader),
+		pos:    position{line: 1, col: 1},
+		start:  position{line: 1, col: 1},
 	}
 }
 
 func (s *scanner) emitItem(it item) {
+	it.pos = s.start
+	if s.currentValue.Len() == 0 {
+		it.pos = s.pos
+	}
 	s.items = append(s.items, it)
 }
 
 // read returns the next rune from the input. It returns rune eof when input is over
-// or readError when another error.
+// or on reader error, which is kept in err.
 func (s *scanner) read() rune {
+	if s.err != nil {
+		return eof
+	}
 	r, _, err := s.reader.ReadRune()
 	if err != nil {
-		if err == io.EOF {
-			return eof
+		if err != io.EOF {
+			s.err = fmt.Errorf("failed to read: %w", err)
 		}
-		log.Fatalln("failed to read:", err)
+		return eof
+	}
+	s.prev = s.pos
+	if r == '\n' {
+		s.pos = position{line: s.pos.line + 1, col: 1}
+	} else {
+		s.pos.col++
 	}
 	return r
 }
@@ -93,7 +137,32 @@ func (s *scanner) read() rune {
 func (s *scanner) unread() {
 	err := s.reader.UnreadRune()
 	if err != nil {
-		log.Fatalln("failed to unread:", err)
+		s.err = fmt.Errorf("failed to unread: %w", err)
+		return
+	}
+	s.pos = s.prev
+}
+
+// lookahead reports whether the input continues with prefix. It does not consume anything.
+func (s *scanner) lookahead(prefix string) bool {
+	b, err := s.reader.Peek(len(prefix))
+	if err != nil {
+		return false
+	}
+	return string(b) == prefix
+}
+
+// peekLine returns the rest of the current line without the line end. It does not consume anything.
+func (s *scanner) peekLine() string {
+	for n := 1; ; n++ {
+		b, err := s.reader.Peek(n)
+		if err != nil {
+			// End of input or the line is longer than the buffer.
+			return string(b)
+		}
+		if b[n-1] == '\n' {
+			return string(b[:n-1])
+		}
 	}
 }
 
@@ -113,6 +182,9 @@ func (s *scanner) accept(fn acceptFn) bool {
 		return false
 	}
 	if fn(ch) {
+		if s.currentValue.Len() == 0 {
+			s.start = s.prev
+		}
 		s.currentValue.WriteRune(ch)
 		return true
 	}
@@ -161,6 +233,26 @@ func (s *scanner) acceptLine() {
 	}
 }
 
+// acceptLineEnd collects a single line end, if any.
+func (s *scanner) acceptLineEnd() {
+	s.accept(func(r rune) bool {
+		return r == '\r'
+	})
+	s.accept(func(r rune) bool {
+		return r == '\n'
+	})
+}
+
+// skipLineEnd consumes a single line end, if any.
+func (s *scanner) skipLineEnd() {
+	if s.peak() == '\r' {
+		s.read()
+	}
+	if s.peak() == '\n' {
+		s.read()
+	}
+}
+
 func (s *scanner) emitError() {
 	s.emitItem(item{
 		tok: tokenError,
@@ -186,31 +278,13 @@ func lexDetectRequest(s *scanner) stateFn {
 	case requestSeparator:
 		s.currentValue.Reset()
 		return lexRequestSeparator
-	case http.MethodGet:
+	case preRequestHandlerStart:
 		s.emitItem(item{
-			tok: tokenVerb,
-			val: http.MethodGet,
+			tok: tokenPreRequestHandler,
+			val: "",
 		})
 		s.currentValue.Reset()
-		return lexRequestUrl
-	case http.MethodPut:
-		s.emitItem(item{
-			tok: tokenVerb,
-			val: http.MethodPut,
-		})
-		return lexRequestUrl
-	case http.MethodPost:
-		s.emitItem(item{
-			tok: tokenVerb,
-			val: http.MethodPost,
-		})
-		return lexRequestUrl
-	case http.MethodDelete:
-		s.emitItem(item{
-			tok: tokenVerb,
-			val: http.MethodDelete,
-		})
-		return lexRequestUrl
+		return lexScript
 	case responseHandlerStart:
 		s.emitItem(item{
 			tok: tokenResponseHandler,
@@ -218,38 +292,197 @@ func lexDetectRequest(s *scanner) stateFn {
 		})
 		s.currentValue.Reset()
 		return lexScript
-		// TODO: it seems like empty line after request has a certain meaning
 	}
+	if isComment(s.currentValue.String()) {
+		return lexComment
+	}
+	if isMethod(s.currentValue.String()) {
+		s.emitItem(item{
+			tok: tokenVerb,
+			val: s.currentValue.String(),
+		})
+		s.currentValue.Reset()
+		return lexRequestUrl
+	}
+	empty := s.currentValue.Len() == 0
 	s.emitError()
-	return nil
+	if empty {
+		return nil
+	}
+	return lexRecover
+}
+
+// lexRecover skips the rest of the line after an error, so the following problems are reported too.
+func lexRecover(s *scanner) stateFn {
+	s.acceptLine()
+	s.currentValue.Reset()
+	return lexIgnore
+}
+
+// isMethod reports whether word looks like HTTP method: standard one (GET, PATCH, ...) or
+// custom upper-case token (i.e. WebDAV PROPFIND).
+func isMethod(word string) bool {
+	if word == "" {
+		return false
+	}
+	for _, r := range word {
+		if (r < 'A' || r > 'Z') && r != '-' && r != '_' {
+			return false
+		}
+	}
+	return true
 }
 
 // lexRequestSeparator detects the name of the request
 func lexRequestSeparator(s *scanner) stateFn {
 	// collect everything until the end of the line
 	s.acceptLine()
-	val := strings.TrimSpace(s.currentValue.String())
-	if len(val) > 0 {
-		s.emitItem(item{
-			tok: tokenRequestSeparator,
-			val: val,
-		})
-		s.currentValue.Reset()
+	s.emitItem(item{
+		tok: tokenRequestSeparator,
+		val: strings.TrimSpace(s.currentValue.String()),
+	})
+	s.currentValue.Reset()
+	return lexIgnore
+}
+
+// isComment reports whether the word starts a comment line.
+func isComment(word string) bool {
+	for _, c := range commentStarts {
+		if strings.HasPrefix(word, c) {
+			return true
+		}
 	}
+	return false
+}
+
+// lexComment emits the rest of the line as a comment.
+func lexComment(s *scanner) stateFn {
+	s.acceptLine()
+	s.emitItem(item{
+		tok: tokenComment,
+		val: strings.TrimSpace(s.currentValue.String()),
+	})
+	s.currentValue.Reset()
 	return lexIgnore
 }
 
-// lexRequestUrl emits the URL
+// lexRequestUrl emits the URL. The rest of the request line (i.e. HTTP version) is ignored.
 func lexRequestUrl(s *scanner) stateFn {
 	s.ignoreWhiteSpaces()
 	s.acceptWord()
-	if s.currentValue.Len() > 0 {
+	if s.currentValue.Len() == 0 {
+		return lexIgnore
+	}
+	s.emitItem(item{
+		tok: tokenURL,
+		val: s.currentValue.String(),
+	})
+	s.currentValue.Reset()
+	// The rest of the request line is the HTTP version, i.e. HTTP/1.1.
+	s.acceptLine()
+	if version := strings.TrimSpace(s.currentValue.String()); version != "" {
 		s.emitItem(item{
-			tok: tokenURL,
-			val: s.currentValue.String(),
+			tok: tokenHTTPVersion,
+			val: version,
+		})
+	}
+	s.currentValue.Reset()
+	s.skipLineEnd()
+	return lexHeaders
+}
+
+// lexHeaders emits headers that follow the request line, one per line, until an empty line.
+func lexHeaders(s *scanner) stateFn {
+	if s.lookahead(requestSeparator) || s.lookahead(responseHandlerStart) {
+		return lexIgnore
+	}
+	s.acceptLine()
+	line := strings.TrimSpace(s.currentValue.String())
+	if isComment(line) {
+		s.emitItem(item{
+			tok: tokenComment,
+			val: line,
+		})
+		s.currentValue.Reset()
+		s.skipLineEnd()
+		return lexHeaders
+	}
+	if line == "" {
+		// Empty line separates headers from the body.
+		s.currentValue.Reset()
+		s.skipLineEnd()
+		return lexBody
+	}
+	name, _, found := strings.Cut(line, ":")
+	name = strings.TrimSpace(name)
+	if !found || name == "" || strings.ContainsAny(name, spaceChars) {
+		s.emitError()
+		s.skipLineEnd()
+		return lexHeaders
+	}
+	s.emitItem(item{
+		tok: tokenHeader,
+		val: line,
+	})
+	s.currentValue.Reset()
+	s.skipLineEnd()
+	return lexHeaders
+}
+
+// lexBody emits everything until the next request separator or response handler as a request body.
+// Blank lines before the body are skipped, a line "< path" makes the file the body.
+func lexBody(s *scanner) stateFn {
+	for s.peak() != eof && strings.TrimSpace(s.peekLine()) == "" {
+		s.acceptLine()
+		s.currentValue.Reset()
+		s.skipLineEnd()
+	}
+	if isBodyFileLine(s.peekLine()) {
+		return lexBodyFile
+	}
+	for s.peak() != eof && !s.lookahead(requestSeparator) && !s.lookahead(responseHandlerStart) {
+		s.acceptLine()
+		s.acceptLineEnd()
+	}
+	body := strings.TrimLeft(s.currentValue.String(), lineEnds)
+	body = strings.TrimRight(body, spaceChars)
+	if body != "" {
+		s.emitItem(item{
+			tok: tokenBody,
+			val: body,
+		})
+	}
+	s.currentValue.Reset()
+	return lexIgnore
+}
+
+// isBodyFileLine reports whether the line is "<" followed by whitespace, so inline bodies
+// starting with "<" (i.e. XML) are not mistaken for a file reference.
+func isBodyFileLine(line string) bool {
+	rest, ok := strings.CutPrefix(strings.TrimRight(line, lineEnds), bodyFileStart)
+	return ok && (rest == "" || strings.ContainsRune(" \t", rune(rest[0])))
+}
+
+// lexBodyFile emits the path of the file that has to be used as a request body.
+func lexBodyFile(s *scanner) stateFn {
+	s.accept(func(r rune) bool {
+		return string(r) == bodyFileStart
+	})
+	s.acceptLine()
+	path := strings.TrimSpace(strings.TrimPrefix(s.currentValue.String(), bodyFileStart))
+	if path == "" {
+		s.emitItem(item{
+			tok: tokenError,
+			val: bodyFileStart,
 		})
 		s.currentValue.Reset()
+		return lexIgnore
 	}
+	s.emitItem(item{
+		tok: tokenBodyFile,
+		val: path,
+	})
+	s.currentValue.Reset()
 	return lexIgnore
 }
 
@@ -277,13 +510,17 @@ func lexScript(s *scanner) stateFn {
 			return lexIgnore
 		}
 		if s.peak() == eof {
+			s.emitError()
 			return nil
 		}
 	}
 }
 
-func (s *scanner) scan() {
+// scan tokenizes the whole input. Problems of the input are emitted as tokenError items, the returned error
+// is the error of the reader.
+func (s *scanner) scan() error {
 	for state := lexIgnore; state != nil; {
 		state = state(s)
 	}
+	return s.err
 }
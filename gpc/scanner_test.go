@@ -1,9 +1,12 @@
 package gpc
 
 import (
+	"errors"
+	"io"
 	"reflect"
 	"strings"
 	"testing"
+	"testing/iotest"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
@@ -15,6 +18,20 @@ func assertFunc(t *testing.T, expected any, given any) bool {
 	return assert.Equal(t, ep, p)
 }
 
+// noPos drops the position, so tests could compare only token and value.
+func noPos(it item) item {
+	it.pos = position{}
+	return it
+}
+
+func noPositions(items []item) []item {
+	var res []item
+	for _, it := range items {
+		res = append(res, noPos(it))
+	}
+	return res
+}
+
 func TestRead(t *testing.T) {
 	t.Run("read empty file", func(t *testing.T) {
 		r := strings.NewReader(``)
@@ -100,20 +117,34 @@ but not this one`)
 		assert.Equal(t, item{
 			tok: tokenVerb,
 			val: "GET",
-		}, s.items[0])
+		}, noPos(s.items[0]))
 	})
 
 	t.Run("detect wrong verb", func(t *testing.T) {
-		r := strings.NewReader(`BLAH example.com`)
+		r := strings.NewReader(`Blah example.com`)
 		s := newScanner(r)
 		fn := lexDetectRequest(s)
 		assert.Equal(t, "", s.currentValue.String())
-		assert.Nil(t, fn)
+		assertFunc(t, lexRecover, fn)
 		assert.Len(t, s.items, 1)
 		assert.Equal(t, item{
 			tok: tokenError,
-			val: "BLAH",
-		}, s.items[0])
+			val: "Blah",
+		}, noPos(s.items[0]))
+	})
+
+	t.Run("detect custom verb", func(t *testing.T) {
+		for _, verb := range []string{"PATCH", "HEAD", "OPTIONS", "TRACE", "CONNECT", "PROPFIND", "VERSION-CONTROL"} {
+			r := strings.NewReader(verb + ` example.com`)
+			s := newScanner(r)
+			fn := lexDetectRequest(s)
+			assertFunc(t, lexRequestUrl, fn)
+			require.Len(t, s.items, 1)
+			assert.Equal(t, item{
+				tok: tokenVerb,
+				val: verb,
+			}, noPos(s.items[0]))
+		}
 	})
 
 	t.Run("detect empty verb", func(t *testing.T) {
@@ -126,7 +157,7 @@ but not this one`)
 		assert.Equal(t, item{
 			tok: tokenError,
 			val: "",
-		}, s.items[0])
+		}, noPos(s.items[0]))
 	})
 
 	t.Run("detect request separator comment", func(t *testing.T) {
@@ -142,7 +173,7 @@ but not this one`)
 		assert.Equal(t, item{
 			tok: tokenRequestSeparator,
 			val: "Make a request",
-		}, s.items[0])
+		}, noPos(s.items[0]))
 	})
 
 	t.Run("detect request handler embedded", func(t *testing.T) {
@@ -154,7 +185,7 @@ but not this one`)
 		assert.Equal(t, item{
 			tok: tokenResponseHandler,
 			val: "",
-		}, s.items[0])
+		}, noPos(s.items[0]))
 
 		fn = lexScript(s)
 		assertFunc(t, lexIgnore, fn)
@@ -162,7 +193,7 @@ but not this one`)
 		assert.Equal(t, item{
 			tok: tokenEmbeddedScript,
 			val: "{% console.log(\"hello\") %}",
-		}, s.items[1])
+		}, noPos(s.items[1]))
 	})
 
 	t.Run("extract embedded script", func(t *testing.T) {
@@ -174,7 +205,7 @@ but not this one`)
 		assert.Equal(t, item{
 			tok: tokenResponseHandler,
 			val: "",
-		}, s.items[0])
+		}, noPos(s.items[0]))
 
 		fn = lexScript(s)
 		assertFunc(t, lexIgnore, fn)
@@ -182,10 +213,17 @@ but not this one`)
 		assert.Equal(t, item{
 			tok: tokenScriptFile,
 			val: "index.js",
-		}, s.items[1])
+		}, noPos(s.items[1]))
 	})
 }
 
+func TestScanReadError(t *testing.T) {
+	r := io.MultiReader(strings.NewReader("GET example.com\n"), iotest.ErrReader(errors.New("broken disk")))
+	s := newScanner(r)
+	err := s.scan()
+	assert.ErrorContains(t, err, "broken disk")
+}
+
 func TestScan(t *testing.T) {
 	t.Run("scan empty file", func(t *testing.T) {
 		r := strings.NewReader(``)
@@ -214,7 +252,7 @@ GET https://example.com
 				val: "https://example.com",
 			},
 		}
-		assert.EqualValues(t, expected, s.items)
+		assert.EqualValues(t, expected, noPositions(s.items))
 	})
 
 	t.Run("scan typical PUT", func(t *testing.T) {
@@ -237,7 +275,7 @@ PUT https://example.com
 				val: "https://example.com",
 			},
 		}
-		assert.EqualValues(t, expected, s.items)
+		assert.EqualValues(t, expected, noPositions(s.items))
 	})
 
 	t.Run("scan typical delete", func(t *testing.T) {
@@ -260,7 +298,7 @@ DELETE https://example.com
 				val: "https://example.com",
 			},
 		}
-		assert.EqualValues(t, expected, s.items)
+		assert.EqualValues(t, expected, noPositions(s.items))
 	})
 
 	t.Run("scan get with request handler", func(t *testing.T) {
@@ -294,6 +332,247 @@ GET https://example.com
 				val: "index.js",
 			},
 		}
-		assert.EqualValues(t, expected, s.items)
+		assert.EqualValues(t, expected, noPositions(s.items))
+	})
+	t.Run("scan get with headers", func(t *testing.T) {
+		r := strings.NewReader(`### Get operation
+GET https://example.com HTTP/1.1
+Authorization: Bearer token
+Accept: application/json
+
+> index.js
+`)
+		s := newScanner(r)
+		s.scan()
+		expected := []item{
+			{
+				tok: tokenRequestSeparator,
+				val: "Get operation",
+			},
+			{
+				tok: tokenVerb,
+				val: "GET",
+			},
+			{
+				tok: tokenURL,
+				val: "https://example.com",
+			},
+			{
+				tok: tokenHTTPVersion,
+				val: "HTTP/1.1",
+			},
+			{
+				tok: tokenHeader,
+				val: "Authorization: Bearer token",
+			},
+			{
+				tok: tokenHeader,
+				val: "Accept: application/json",
+			},
+			{
+				tok: tokenResponseHandler,
+				val: "",
+			},
+			{
+				tok: tokenScriptFile,
+				val: "index.js",
+			},
+		}
+		assert.EqualValues(t, expected, noPositions(s.items))
+	})
+
+	t.Run("scan malformed header", func(t *testing.T) {
+		r := strings.NewReader(`GET https://example.com
+not a header
+`)
+		s := newScanner(r)
+		s.scan()
+		require.Len(t, s.items, 3)
+		assert.Equal(t, item{
+			tok: tokenError,
+			val: "not a header",
+		}, noPos(s.items[2]))
+	})
+	t.Run("scan put with body", func(t *testing.T) {
+		r := strings.NewReader(`### Put operation
+PUT https://example.com
+Content-Type: application/json
+
+{"name": "Hello"}
+
+### Next operation
+`)
+		s := newScanner(r)
+		s.scan()
+		expected := []item{
+			{
+				tok: tokenRequestSeparator,
+				val: "Put operation",
+			},
+			{
+				tok: tokenVerb,
+				val: "PUT",
+			},
+			{
+				tok: tokenURL,
+				val: "https://example.com",
+			},
+			{
+				tok: tokenHeader,
+				val: "Content-Type: application/json",
+			},
+			{
+				tok: tokenBody,
+				val: `{"name": "Hello"}`,
+			},
+			{
+				tok: tokenRequestSeparator,
+				val: "Next operation",
+			},
+		}
+		assert.EqualValues(t, expected, noPositions(s.items))
+	})
+	t.Run("scan post with body file", func(t *testing.T) {
+		r := strings.NewReader(`POST https://example.com
+Content-Type: application/json
+
+< ./payload.json
+`)
+		s := newScanner(r)
+		s.scan()
+		require.Len(t, s.items, 4)
+		assert.Equal(t, item{
+			tok: tokenBodyFile,
+			val: "./payload.json",
+		}, noPos(s.items[3]))
+	})
+	t.Run("scan body file after extra blank lines", func(t *testing.T) {
+		r := strings.NewReader(`POST https://example.com
+
+
+< ./payload.json
+`)
+		s := newScanner(r)
+		s.scan()
+		require.Len(t, s.items, 3)
+		assert.Equal(t, item{
+			tok: tokenBodyFile,
+			val: "./payload.json",
+			pos: position{line: 4, col: 1},
+		}, s.items[2])
+	})
+	t.Run("scan inline XML body", func(t *testing.T) {
+		r := strings.NewReader(`POST https://example.com
+Content-Type: application/xml
+
+<note><to>a</to></note>
+`)
+		s := newScanner(r)
+		s.scan()
+		require.Len(t, s.items, 4)
+		assert.Equal(t, item{
+			tok: tokenBody,
+			val: "<note><to>a</to></note>",
+		}, noPos(s.items[3]))
+	})
+	t.Run("scan inline body after extra blank lines", func(t *testing.T) {
+		r := strings.NewReader("POST https://example.com\n\n  \n\n<a/>\n")
+		s := newScanner(r)
+		s.scan()
+		require.Len(t, s.items, 3)
+		assert.Equal(t, item{
+			tok: tokenBody,
+			val: "<a/>",
+		}, noPos(s.items[2]))
+	})
+	t.Run("scan comments", func(t *testing.T) {
+		r := strings.NewReader(`###
+# @name first
+GET https://example.com
+// header comment
+Accept: */*
+`)
+		s := newScanner(r)
+		s.scan()
+		expected := []item{
+			{
+				tok: tokenRequestSeparator,
+				val: "",
+			},
+			{
+				tok: tokenComment,
+				val: "# @name first",
+			},
+			{
+				tok: tokenVerb,
+				val: "GET",
+			},
+			{
+				tok: tokenURL,
+				val: "https://example.com",
+			},
+			{
+				tok: tokenComment,
+				val: "// header comment",
+			},
+			{
+				tok: tokenHeader,
+				val: "Accept: */*",
+			},
+		}
+		assert.EqualValues(t, expected, noPositions(s.items))
+	})
+	t.Run("scan pre-request handler", func(t *testing.T) {
+		r := strings.NewReader(`###
+< {% request.variables.set("id", "42") %}
+GET https://example.com/{{id}}
+`)
+		s := newScanner(r)
+		s.scan()
+		expected := []item{
+			{
+				tok: tokenRequestSeparator,
+				val: "",
+			},
+			{
+				tok: tokenPreRequestHandler,
+				val: "",
+			},
+			{
+				tok: tokenEmbeddedScript,
+				val: `{% request.variables.set("id", "42") %}`,
+			},
+			{
+				tok: tokenVerb,
+				val: "GET",
+			},
+			{
+				tok: tokenURL,
+				val: "https://example.com/{{id}}",
+			},
+		}
+		assert.EqualValues(t, expected, noPositions(s.items))
+	})
+	t.Run("item positions", func(t *testing.T) {
+		r := strings.NewReader(`### Get operation
+GET https://example.com
+Accept: */*
+
+> {% console.log("hello") %}
+`)
+		s := newScanner(r)
+		s.scan()
+		var positions []position
+		for _, it := range s.items {
+			positions = append(positions, it.pos)
+		}
+		assert.Equal(t, []position{
+			{line: 1, col: 4},
+			{line: 2, col: 1},
+			{line: 2, col: 5},
+			{line: 3, col: 1},
+			{line: 5, col: 1},
+			{line: 5, col: 3},
+		}, positions)
 	})
 }
@@ -2,9 +2,14 @@ package gpc
 
 import (
 	_ "embed"
+	"errors"
+	"fmt"
 	"io"
+	"mime"
 	"net/http"
+	"strconv"
 	"strings"
+	"time"
 
 	"github.com/dop251/goja"
 	"github.com/dop251/goja_nodejs/console"
@@ -14,7 +19,12 @@ import (
 //go:embed client.js
 var clientSource string
 
-type playEnvironment struct{}
+const clientScriptName = "client.js"
+
+// playEnvironment is the state shared by scripts of all steps.
+type playEnvironment struct {
+	globals *globalStore
+}
 
 type results struct {
 	value goja.Value
@@ -41,23 +51,114 @@ func (s *scriptOutput) Output() string {
 	return s.content.String()
 }
 
+// ResponseAdapter is the response object available to response handlers. Body is parsed into an object when
+// the content type is JSON, otherwise it is a string.
 type ResponseAdapter struct {
-	Status int    `json:"status"`
-	Body   string `json:"body"`
+	Status      int              `json:"status"`
+	Body        any              `json:"body"`
+	Headers     *responseHeaders `json:"headers"`
+	ContentType ContentType      `json:"contentType"`
+}
+
+// ContentType is response.contentType
+type ContentType struct {
+	MimeType string `json:"mimeType"`
+	Charset  string `json:"charset"`
+}
+
+// parseContentType splits Content-Type header into mime type and charset.
+func parseContentType(value string) ContentType {
+	if value == "" {
+		return ContentType{}
+	}
+	mimeType, params, err := mime.ParseMediaType(value)
+	if err != nil {
+		mimeType, _, _ = strings.Cut(value, ";")
+		return ContentType{MimeType: strings.TrimSpace(mimeType)}
+	}
+	return ContentType{
+		MimeType: mimeType,
+		Charset:  params["charset"],
+	}
+}
+
+// isJSON reports whether the content with the mime type has to be parsed as JSON.
+func (c ContentType) isJSON() bool {
+	return c.MimeType == "application/json" || strings.HasSuffix(c.MimeType, "+json")
+}
+
+// responseHeaders is response.headers
+type responseHeaders struct {
+	header http.Header
+}
+
+// ValueOf is response.headers.valueOf(name), returns the first value or null when there is no such header.
+func (h *responseHeaders) ValueOf(name string) any {
+	values := h.header.Values(name)
+	if len(values) == 0 {
+		return nil
+	}
+	return values[0]
+}
+
+// ValuesOf is response.headers.valuesOf(name), returns all values of the header.
+func (h *responseHeaders) ValuesOf(name string) []string {
+	values := h.header.Values(name)
+	if values == nil {
+		return []string{}
+	}
+	return values
 }
 
 type executeResult struct {
 	console  string
+	tests    []TestResult
 	failures []string
 }
 
-// executeResponseHandler executes response handler and returns the result of test execution along with console output.
-func executeResponseHandler(source string, env *playEnvironment, response http.Response, out *results) (result executeResult, err error) {
-	printer := &scriptOutput{}
-	defer func() {
-		result.console = printer.Output()
-	}()
+// TestResult is the outcome of one client.test of the response handler.
+type TestResult struct {
+	Name     string
+	Passed   bool
+	Message  string // Message of the error that failed the test.
+	Stack    string // JS stack trace of the error.
+	Duration time.Duration
+
+	// Location of the failure in the script: .http file for embedded scripts or .js file.
+	File   string
+	Line   int
+	Column int
+}
+
+// Location returns file:line:column of the failure, empty when it is unknown.
+func (t TestResult) Location() string {
+	if t.Line == 0 {
+		return ""
+	}
+	return fmt.Sprintf("%s:%d:%d", t.File, t.Line, t.Column)
+}
+
+// scriptCode is the source of the script and the name used for it in stack traces. Embedded scripts are
+// padded, so positions in stack traces match the positions in the .http file.
+type scriptCode struct {
+	name   string
+	source string
+}
+
+// embeddedScriptCode places the content of embedded script at its position in the file.
+func embeddedScriptCode(fileName string, content string, pos position) scriptCode {
+	padding := ""
+	if pos.line > 0 && pos.col > 0 {
+		padding = strings.Repeat("\n", pos.line-1) + strings.Repeat(" ", pos.col-1)
+	}
+	return scriptCode{
+		name:   fileName,
+		source: padding + content,
+	}
+}
 
+// newRuntime creates VM with console that writes to the printer and initialized client object.
+func newRuntime(env *playEnvironment, printer *scriptOutput) (*goja.Runtime, error) {
 	registry := new(require.Registry) // this can be shared by multiple runtimes
 
 	vm := goja.New()
@@ -67,40 +168,161 @@ func executeResponseHandler(source string, env *playEnvironment, response http.R
 	registry.RegisterNativeModule(console.ModuleName, console.RequireWithPrinter(printer))
 	console.Enable(vm)
 
+	// TODO: verify proper client initialization, at least no errors
+	_, err := vm.RunScript(clientScriptName, clientSource)
+	if err != nil {
+		return nil, err
+	}
+	if env != nil {
+		client, err := vm.RunString("client")
+		if err != nil {
+			return nil, err
+		}
+		err = client.ToObject(vm).Set("global", env.globals)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return vm, nil
+}
+
+// executePreRequestHandler executes pre-request handler that can inspect the request and set request variables.
+func executePreRequestHandler(code scriptCode, env *playEnvironment, request *RequestAdapter) (result executeResult, err error) {
+	printer := &scriptOutput{}
+	defer func() {
+		result.console = printer.Output()
+	}()
+
+	vm, err := newRuntime(env, printer)
+	if err != nil {
+		return
+	}
+	err = vm.Set("request", request)
+	if err != nil {
+		return
+	}
+	_, err = vm.RunScript(code.name, code.source)
+	return
+}
+
+// parseJSON parses the text with JSON.parse of the VM, so scripts get native objects and arrays.
+func parseJSON(vm *goja.Runtime, text string) (goja.Value, error) {
+	parse, ok := goja.AssertFunction(vm.Get("JSON").ToObject(vm).Get("parse"))
+	if !ok {
+		return nil, errors.New("JSON.parse is not available")
+	}
+	return parse(goja.Undefined(), vm.ToValue(text))
+}
+
+// executeResponseHandler executes response handler and returns the result of test execution along with console output.
+func executeResponseHandler(code scriptCode, env *playEnvironment, response http.Response, out *results) (result executeResult, err error) {
+	printer := &scriptOutput{}
+	defer func() {
+		result.console = printer.Output()
+	}()
+
+	vm, err := newRuntime(env, printer)
+	if err != nil {
+		return
+	}
+
 	r := ResponseAdapter{
-		Status: response.StatusCode,
+		Status:      response.StatusCode,
+		Body:        "",
+		Headers:     &responseHeaders{header: response.Header},
+		ContentType: parseContentType(response.Header.Get("Content-Type")),
 	}
-	if response.Body != nil {
+	if response.Body != nil && response.Body != http.NoBody {
+		defer response.Body.Close()
 		var body []byte
 		body, err = io.ReadAll(response.Body)
 		if err != nil {
 			return
 		}
-		defer response.Body.Close()
-		// TODO: it seems like httpclient tool detects json output and formats it. See httputil.DumpResponse(&response, true)
 		r.Body = string(body)
+		if r.ContentType.isJSON() && len(body) > 0 {
+			// Like in JetBrains client, the body that is not valid JSON stays a string.
+			if parsed, err := parseJSON(vm, string(body)); err == nil {
+				r.Body = parsed
+			}
+		}
 	}
 	err = vm.Set("response", r)
 	if err != nil {
 		return
 	}
 
-	// TODO: verify proper client initialization, at least no errors
-	_, err = vm.RunString(clientSource)
+	out.value, err = vm.RunScript(code.name, code.source)
 	if err != nil {
 		return
 	}
-	out.value, err = vm.RunString(source)
+
+	err = runTests(vm, code, printer, &result)
+	return
+}
+
+// runTests runs tests declared with client.test one by one and records the result of each.
+func runTests(vm *goja.Runtime, code scriptCode, printer *scriptOutput, result *executeResult) error {
+	declared, err := vm.RunString("client._tests")
 	if err != nil {
-		return
+		return err
 	}
+	tests := declared.ToObject(vm)
+	count := tests.Get("length").ToInteger()
+	for i := int64(0); i < count; i++ {
+		t := tests.Get(strconv.FormatInt(i, 10)).ToObject(vm)
+		name := t.Get("testName").String()
+		fn, ok := goja.AssertFunction(t.Get("func"))
+		if !ok {
+			return fmt.Errorf("test %q is not a function", name)
+		}
 
-	// Run declared tests and process the results.
-	testResult, err := vm.RunString("client.runTests()")
-	ex := testResult.ToObject(vm)
-	for _, key := range ex.Keys() {
-		// TODO: it is slightly hacky, need to make strict abstraction
-		result.failures = append(result.failures, ex.Get(key).String())
+		printer.Log("RUN: " + name)
+		start := time.Now()
+		_, err := fn(goja.Undefined(), vm.Get("response"))
+		tr := TestResult{
+			Name:     name,
+			Passed:   err == nil,
+			Duration: time.Since(start),
+		}
+		var ex *goja.Exception
+		if err != nil && !errors.As(err, &ex) {
+			return err
+		}
+		if ex != nil {
+			printer.Log("FAILED: " + name)
+			printer.Log(ex.Value().String())
+			tr.Message = exceptionMessage(ex)
+			tr.Stack = ex.String()
+			tr.File, tr.Line, tr.Column = exceptionLocation(ex, code.name)
+			result.failures = append(result.failures, ex.Value().String())
+		} else {
+			printer.Log("PASS: " + name)
+		}
+		result.tests = append(result.tests, tr)
 	}
-	return
+	return nil
+}
+
+// exceptionLocation returns position of the innermost stack frame that belongs to the script, so assertion
+// helpers of client.js are skipped.
+func exceptionLocation(ex *goja.Exception, name string) (string, int, int) {
+	for _, frame := range ex.Stack() {
+		if frame.SrcName() != name {
+			continue
+		}
+		pos := frame.Position()
+		return name, pos.Line, pos.Column
+	}
+	return "", 0, 0
+}
+
+// exceptionMessage returns message of the thrown Error or the thrown value itself.
+func exceptionMessage(ex *goja.Exception) string {
+	if obj, ok := ex.Value().(*goja.Object); ok {
+		if m := obj.Get("message"); m != nil && !goja.IsUndefined(m) {
+			return m.String()
+		}
+	}
+	return ex.Value().String()
 }
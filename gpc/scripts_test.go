@@ -1,20 +1,26 @@
 package gpc
 
 import (
+	"io"
 	"net/http"
 	"os"
+	"strings"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
 
+func code(source string) scriptCode {
+	return scriptCode{name: "test.js", source: source}
+}
+
 func TestHelloWorld(t *testing.T) {
 	resp := http.Response{
 		StatusCode: http.StatusOK,
 	}
 	result := results{}
-	output, err := executeResponseHandler("console.log('Hello World', response.status)", nil, resp, &result)
+	output, err := executeResponseHandler(code("console.log('Hello World', response.status)"), nil, resp, &result)
 	require.NoError(t, err)
 	require.Equal(t, "Hello World 200\n", output.console)
 }
@@ -31,7 +37,7 @@ func TestClient(t *testing.T) {
 			StatusCode: http.StatusOK,
 		}
 		result := results{}
-		output, err := executeResponseHandler("client.log(`Hello ${client.name}`)", nil, resp, &result)
+		output, err := executeResponseHandler(code("client.log(`Hello ${client.name}`)"), nil, resp, &result)
 		require.NoError(t, err)
 		require.Equal(t, "Hello HTTP Client\n", output.console)
 	})
@@ -41,7 +47,7 @@ func TestClient(t *testing.T) {
 			StatusCode: http.StatusOK,
 		}
 		result := results{}
-		output, err := executeResponseHandler("client.test('first', function() {client.assert(response.status === 200, \"Response status is not 200\");})", nil, resp, &result)
+		output, err := executeResponseHandler(code("client.test('first', function() {client.assert(response.status === 200, \"Response status is not 200\");})"), nil, resp, &result)
 		require.NoError(t, err)
 		require.Equal(t, `RUN: first
 PASS: first
@@ -53,7 +59,7 @@ PASS: first
 			StatusCode: http.StatusNotFound,
 		}
 		result := results{}
-		output, err := executeResponseHandler("client.test('second', function() {client.assert(response.status === 200, \"Response status is not 200\");})", nil, resp, &result)
+		output, err := executeResponseHandler(code("client.test('second', function() {client.assert(response.status === 200, \"Response status is not 200\");})"), nil, resp, &result)
 		require.NoError(t, err)
 		require.Equal(t, `RUN: second
 FAILED: second
@@ -62,4 +68,143 @@ Error: Response status is not 200
 		require.Len(t, output.failures, 1)
 		assert.Equal(t, output.failures[0], "Error: Response status is not 200")
 	})
+
+	t.Run("structured test results", func(t *testing.T) {
+		resp := http.Response{
+			StatusCode: http.StatusOK,
+		}
+		result := results{}
+		output, err := executeResponseHandler(code(`
+client.test("passing", function() {});
+client.test("failing", function() {
+	client.assert(false, "not good");
+});
+client.test("throwing string", function() {
+	throw "plain";
+});
+`), nil, resp, &result)
+		require.NoError(t, err)
+		require.Len(t, output.tests, 3)
+		assert.Equal(t, "passing", output.tests[0].Name)
+		assert.True(t, output.tests[0].Passed)
+		assert.Empty(t, output.tests[0].Message)
+
+		assert.Equal(t, "failing", output.tests[1].Name)
+		assert.False(t, output.tests[1].Passed)
+		assert.Equal(t, "not good", output.tests[1].Message)
+		assert.Contains(t, output.tests[1].Stack, "at ")
+
+		assert.Equal(t, "plain", output.tests[2].Message)
+		assert.Equal(t, []string{"Error: not good", "plain"}, output.failures)
+	})
+	t.Run("globals", func(t *testing.T) {
+		resp := http.Response{
+			StatusCode: http.StatusOK,
+		}
+		env := &playEnvironment{globals: newGlobalStore()}
+		result := results{}
+		_, err := executeResponseHandler(code(`
+client.global.set("token", "secret");
+client.global.set("count", 42);
+client.global.set("removed", "x");
+client.global.clear("removed");
+`), env, resp, &result)
+		require.NoError(t, err)
+
+		output, err := executeResponseHandler(code(`
+console.log(client.global.isEmpty(), client.global.get("token"), client.global.get("count"), client.global.get("removed"));
+client.global.clearAll();
+console.log(client.global.isEmpty());
+`), env, resp, &result)
+		require.NoError(t, err)
+		assert.Equal(t, "false secret 42 null\ntrue\n", output.console)
+	})
+	t.Run("pre-request handler", func(t *testing.T) {
+		variables := newRequestVariables()
+		lookup := func(name string) (string, bool) {
+			if v, ok := variables.lookup(name); ok {
+				return v, true
+			}
+			return "", false
+		}
+		request := newRequestAdapter(step{
+			method: "POST",
+			url:    "example.com/{{id}}",
+			headers: []header{
+				{name: "X-Signature", value: "{{sig}}"},
+			},
+			body: "payload",
+		}, variables, map[string]string{"host": "example.com"}, lookup)
+		output, err := executePreRequestHandler(code(`
+request.variables.set("id", "42");
+request.variables.set("sig", request.method + ":" + request.body.getRaw().length);
+console.log(request.url.getRaw(), request.url.tryGetSubstituted());
+console.log(request.headers.findByName("x-signature").tryGetSubstitutedValue());
+console.log(request.headers.all().length, request.headers.findByName("missing"));
+console.log(request.environment.get("host"), request.variables.get("id"));
+`), &playEnvironment{globals: newGlobalStore()}, request)
+		require.NoError(t, err)
+		assert.Equal(t, `example.com/{{id}} example.com/42
+POST:7
+1 null
+example.com 42
+`, output.console)
+	})
+	t.Run("response headers and json body", func(t *testing.T) {
+		resp := http.Response{
+			StatusCode: http.StatusOK,
+			Header: http.Header{
+				"Content-Type": []string{"application/json; charset=UTF-8"},
+				"Set-Cookie":   []string{"a=1", "b=2"},
+			},
+			Body: io.NopCloser(strings.NewReader(`{"name": "Hello", "items": [1, 2]}`)),
+		}
+		result := results{}
+		output, err := executeResponseHandler(code(`
+console.log(response.contentType.mimeType, response.contentType.charset);
+console.log(response.headers.valueOf("content-type"), response.headers.valueOf("missing"));
+console.log(response.headers.valuesOf("Set-Cookie").join(","), response.headers.valuesOf("missing").length);
+console.log(response.body.name, response.body.items.length);
+`), nil, resp, &result)
+		require.NoError(t, err)
+		assert.Equal(t, `application/json UTF-8
+application/json; charset=UTF-8 null
+a=1,b=2 0
+Hello 2
+`, output.console)
+	})
+
+	t.Run("response text body", func(t *testing.T) {
+		resp := http.Response{
+			StatusCode: http.StatusOK,
+			Header: http.Header{
+				"Content-Type": []string{"text/plain"},
+			},
+			Body: io.NopCloser(strings.NewReader(`{"name": "Hello"}`)),
+		}
+		result := results{}
+		output, err := executeResponseHandler(code(`console.log(typeof response.body, response.body)`), nil, resp, &result)
+		require.NoError(t, err)
+		assert.Equal(t, "string {\"name\": \"Hello\"}\n", output.console)
+	})
+	t.Run("response invalid json body", func(t *testing.T) {
+		resp := http.Response{
+			StatusCode: http.StatusInternalServerError,
+			Header: http.Header{
+				"Content-Type": []string{"application/json"},
+			},
+			Body: io.NopCloser(strings.NewReader(`oops, not json`)),
+		}
+		result := results{}
+		output, err := executeResponseHandler(code(`
+console.log(typeof response.body, response.body)
+client.test("status", function() {
+	client.assert(response.status === 200, "unexpected status")
+})
+`), nil, resp, &result)
+		require.NoError(t, err)
+		assert.Contains(t, output.console, "string oops, not json\n")
+		require.Len(t, output.tests, 1)
+		assert.False(t, output.tests[0].Passed)
+	})
 }
@@ -0,0 +1,74 @@
+package gpc
+
+import (
+	"context"
+	"errors"
+)
+
+// StepErrorKind tells what part of the step failed.
+type StepErrorKind int
+
+const (
+	// RequestError means the request could not be built, i.e. unresolved variables or missing body file.
+	RequestError StepErrorKind = iota + 1
+	// TransportError means the request could not be sent or the response could not be read, including timeouts.
+	TransportError
+	// ScriptError means pre-request or response handler could not be loaded or threw an exception.
+	ScriptError
+	// AssertionFailure means at least one client.test of the response handler failed.
+	AssertionFailure
+)
+
+func (k StepErrorKind) String() string {
+	switch k {
+	case RequestError:
+		return "request error"
+	case TransportError:
+		return "transport error"
+	case ScriptError:
+		return "script error"
+	case AssertionFailure:
+		return "assertion failure"
+	default:
+		return "unknown error"
+	}
+}
+
+// id returns the identifier of the kind used in JSON reports. Unlike String, it is part of the JSON schema.
+func (k StepErrorKind) id() string {
+	switch k {
+	case RequestError:
+		return "request"
+	case TransportError:
+		return "transport"
+	case ScriptError:
+		return "script"
+	case AssertionFailure:
+		return "assertion"
+	default:
+		return "unknown"
+	}
+}
+
+// StepError is the failure recorded on the step in the Report.
+type StepError struct {
+	Kind StepErrorKind
+	Err  error
+}
+
+func (e *StepError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *StepError) Unwrap() error {
+	return e.Err
+}
+
+// stopsPlay reports whether following steps can't be played after the failure. Failed assertions and
+// timeouts only fail the step.
+func (e *StepError) stopsPlay() bool {
+	if e.Kind == AssertionFailure {
+		return false
+	}
+	return !(e.Kind == TransportError && errors.Is(e.Err, context.DeadlineExceeded))
+}
@@ -0,0 +1,92 @@
+package gpc
+
+import (
+	"context"
+	"errors"
+	"net"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/strotz/goplaycalls/pipes"
+)
+
+func TestContinueOnError(t *testing.T) {
+	pipeName := t.Name()
+	ts := newEchoServer(pipeName)
+	ts.Start()
+	t.Cleanup(ts.Stop)
+
+	up := pipes.CreateDialer(pipeName)
+	dialer := func(ctx context.Context, network, addr string) (net.Conn, error) {
+		if addr == "down:80" {
+			return nil, errors.New("host is down")
+		}
+		return up(ctx, network, addr)
+	}
+
+	recipe := `### Transport
+GET http://down/a
+
+### Script
+GET http://localhost/a
+
+> {% throw new Error("broken script") %}
+
+### Assertion
+GET http://localhost/a
+
+> {%
+client.test("fails", function() {
+	client.assert(false, "expected failure");
+});
+%}
+
+### Request
+GET http://localhost/{{missing}}
+
+### Passing
+GET http://localhost/a
+`
+
+	t.Run("stops on the first error", func(t *testing.T) {
+		p, err := ParseString(recipe)
+		require.NoError(t, err)
+		p.Dialer = dialer
+		r, err := p.Play()
+		assert.ErrorContains(t, err, "host is down")
+		require.Len(t, r.Steps(), 1)
+		assert.True(t, r.Steps()[0].Failed())
+	})
+
+	t.Run("continues on error", func(t *testing.T) {
+		p, err := ParseString(recipe)
+		require.NoError(t, err)
+		p.Dialer = dialer
+		p.ContinueOnError = true
+		r, err := p.Play()
+		require.NoError(t, err)
+		require.Len(t, r.Steps(), 5)
+
+		kinds := []StepErrorKind{TransportError, ScriptError, AssertionFailure, RequestError}
+		for i, kind := range kinds {
+			var stepErr *StepError
+			require.ErrorAs(t, r.Steps()[i].Err(), &stepErr, r.Steps()[i].step.name)
+			assert.Equal(t, kind, stepErr.Kind, r.Steps()[i].step.name)
+		}
+		assert.ErrorContains(t, r.Steps()[1].Err(), "broken script")
+		assert.EqualError(t, r.Steps()[2].Err(), "1 of 1 tests failed")
+		assert.NoError(t, r.Steps()[4].Err())
+		assert.False(t, r.Steps()[4].Failed())
+		assert.True(t, r.TestFailed())
+	})
+}
+
+func TestStepErrorKindID(t *testing.T) {
+	// Identifiers are part of the JSON schema, they must not follow the changes of String.
+	assert.Equal(t, "request", RequestError.id())
+	assert.Equal(t, "transport", TransportError.id())
+	assert.Equal(t, "script", ScriptError.id())
+	assert.Equal(t, "assertion", AssertionFailure.id())
+}
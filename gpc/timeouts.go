@@ -0,0 +1,79 @@
+package gpc
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/strotz/goplaycalls/pipes"
+)
+
+// Metadata that limits the time of the request: # @timeout 5 and # @connection-timeout 500ms.
+// Numbers without units are seconds.
+const (
+	metadataTimeout           = "timeout"
+	metadataConnectionTimeout = "connection-timeout"
+)
+
+// parseTimeout parses the value of timeout metadata.
+func parseTimeout(value string) (time.Duration, error) {
+	value = strings.ReplaceAll(value, " ", "")
+	if seconds, err := strconv.ParseFloat(value, 64); err == nil {
+		return time.Duration(seconds * float64(time.Second)), nil
+	}
+	d, err := time.ParseDuration(value)
+	if err != nil {
+		return 0, fmt.Errorf("invalid timeout %q", value)
+	}
+	return d, nil
+}
+
+// stepTimeouts returns timeouts declared with metadata of the step, zero when not declared.
+func stepTimeouts(s step) (timeout time.Duration, connectionTimeout time.Duration, err error) {
+	if v, ok := s.metadata[metadataTimeout]; ok {
+		timeout, err = parseTimeout(v)
+		if err != nil {
+			return
+		}
+	}
+	if v, ok := s.metadata[metadataConnectionTimeout]; ok {
+		connectionTimeout, err = parseTimeout(v)
+	}
+	return
+}
+
+type connectionTimeoutKey struct{}
+
+// withConnectionTimeout passes connection timeout of the request to the dialer.
+func withConnectionTimeout(ctx context.Context, timeout time.Duration) context.Context {
+	if timeout <= 0 {
+		return ctx
+	}
+	return context.WithValue(ctx, connectionTimeoutKey{}, timeout)
+}
+
+// limitDial wraps the dialer, so it honors connection timeout of the request.
+func limitDial(dial pipes.DialerFunc) pipes.DialerFunc {
+	return func(ctx context.Context, network, addr string) (net.Conn, error) {
+		if timeout, ok := ctx.Value(connectionTimeoutKey{}).(time.Duration); ok {
+			var cancel context.CancelFunc
+			ctx, cancel = context.WithTimeout(ctx, timeout)
+			defer cancel()
+		}
+		return dial(ctx, network, addr)
+	}
+}
+
+// newTransport creates transport that dials with the dialer, the default one is used when it is nil.
+func newTransport(dial pipes.DialerFunc) *http.Transport {
+	t := http.DefaultTransport.(*http.Transport).Clone()
+	if dial == nil {
+		dial = (&net.Dialer{}).DialContext
+	}
+	t.DialContext = limitDial(dial)
+	return t
+}
@@ -0,0 +1,92 @@
+package gpc
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseTimeout(t *testing.T) {
+	tests := map[string]time.Duration{
+		"5":     5 * time.Second,
+		"0.5":   500 * time.Millisecond,
+		"200ms": 200 * time.Millisecond,
+		"2 m":   2 * time.Minute,
+	}
+	for value, expected := range tests {
+		d, err := parseTimeout(value)
+		require.NoError(t, err, value)
+		assert.Equal(t, expected, d, value)
+	}
+	_, err := parseTimeout("soon")
+	assert.Error(t, err)
+}
+
+func TestPlayTimeouts(t *testing.T) {
+	release := make(chan struct{})
+	t.Cleanup(func() { close(release) })
+	sm := http.NewServeMux()
+	sm.HandleFunc("GET /slow", func(response http.ResponseWriter, req *http.Request) {
+		select {
+		case <-release:
+		case <-req.Context().Done():
+		}
+	})
+	sm.HandleFunc("GET /fast", echoHandler)
+
+	p, err := ParseString(`### Slow
+# @timeout 50ms
+GET http://in-process/slow
+
+### Fast
+GET http://in-process/fast
+`)
+	require.NoError(t, err)
+	p.Handler = sm
+
+	t.Run("timeout is a failed step", func(t *testing.T) {
+		r, err := p.Play()
+		require.NoError(t, err)
+		require.Len(t, r.Steps(), 2)
+		assert.True(t, r.Steps()[0].Failed())
+		assert.ErrorIs(t, r.Steps()[0].Err(), context.DeadlineExceeded)
+		assert.False(t, r.Steps()[1].Failed())
+		assert.True(t, r.TestFailed())
+	})
+
+	t.Run("cancelled context stops the play", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		_, err := p.PlayContext(ctx)
+		assert.ErrorIs(t, err, context.Canceled)
+	})
+
+	t.Run("invalid timeout", func(t *testing.T) {
+		p, err := ParseString(`# @timeout soon
+GET http://in-process/fast
+`)
+		require.NoError(t, err)
+		p.Handler = sm
+		_, err = p.Play()
+		assert.ErrorContains(t, err, `invalid timeout "soon"`)
+	})
+}
+
+func TestConnectionTimeout(t *testing.T) {
+	dialed := make(chan time.Duration, 1)
+	dial := limitDial(func(ctx context.Context, network, addr string) (net.Conn, error) {
+		deadline, ok := ctx.Deadline()
+		require.True(t, ok)
+		dialed <- time.Until(deadline)
+		return nil, errors.New("not connected")
+	})
+	_, err := dial(withConnectionTimeout(context.Background(), time.Minute), "tcp", "localhost:80")
+	assert.Error(t, err)
+	assert.InDelta(t, time.Minute, <-dialed, float64(time.Second))
+}
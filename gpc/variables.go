@@ -0,0 +1,67 @@
+package gpc
+
+import (
+	"regexp"
+	"slices"
+	"strings"
+)
+
+// variablePattern matches {{name}} placeholders, spaces around the name are allowed.
+var variablePattern = regexp.MustCompile(`{{\s*([^{}\s]+)\s*}}`)
+
+// lookupFn returns the value of the variable and reports whether it is defined.
+type lookupFn func(name string) (string, bool)
+
+// UnresolvedVariablesError lists variables that are referenced by the request, but have no values.
+type UnresolvedVariablesError struct {
+	Names []string
+}
+
+func (e *UnresolvedVariablesError) Error() string {
+	return "unresolved variables: " + strings.Join(e.Names, ", ")
+}
+
+// substitution expands placeholders and keeps track of variables that can't be resolved.
+type substitution struct {
+	lookup     lookupFn
+	unresolved []string
+}
+
+// expand replaces all {{name}} placeholders in v with values of the variables.
+func (s *substitution) expand(v string) string {
+	return variablePattern.ReplaceAllStringFunc(v, func(match string) string {
+		name := variablePattern.FindStringSubmatch(match)[1]
+		value, ok := s.lookup(name)
+		if !ok {
+			if !slices.Contains(s.unresolved, name) {
+				s.unresolved = append(s.unresolved, name)
+			}
+			return match
+		}
+		return value
+	})
+}
+
+// err returns UnresolvedVariablesError if any variable was not resolved.
+func (s *substitution) err() error {
+	if len(s.unresolved) == 0 {
+		return nil
+	}
+	return &UnresolvedVariablesError{Names: s.unresolved}
+}
+
+// expandStep returns a copy of the step with all placeholders in URL, headers and body replaced.
+func expandStep(st step, lookup lookupFn) (step, error) {
+	s := substitution{lookup: lookup}
+	res := st
+	res.url = s.expand(st.url)
+	res.headers = nil
+	for _, h := range st.headers {
+		res.headers = append(res.headers, header{
+			name:  s.expand(h.name),
+			value: s.expand(h.value),
+		})
+	}
+	res.body = s.expand(st.body)
+	return res, s.err()
+}
@@ -0,0 +1,54 @@
+package gpc
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestExpandStep(t *testing.T) {
+	values := map[string]string{
+		"host":  "http://localhost:8080",
+		"id":    "42",
+		"token": "secret",
+	}
+	lookup := func(name string) (string, bool) {
+		v, ok := values[name]
+		return v, ok
+	}
+
+	t.Run("all resolved", func(t *testing.T) {
+		s, err := expandStep(step{
+			method: "POST",
+			url:    "{{host}}/users/{{ id }}",
+			headers: []header{
+				{name: "Authorization", value: "Bearer {{token}}"},
+			},
+			body: `{"id": {{id}}}`,
+		}, lookup)
+		require.NoError(t, err)
+		assert.Equal(t, step{
+			method: "POST",
+			url:    "http://localhost:8080/users/42",
+			headers: []header{
+				{name: "Authorization", value: "Bearer secret"},
+			},
+			body: `{"id": 42}`,
+		}, s)
+	})
+
+	t.Run("unresolved", func(t *testing.T) {
+		_, err := expandStep(step{
+			method: "GET",
+			url:    "{{host}}/{{missing}}/{{other}}",
+			headers: []header{
+				{name: "X-Missing", value: "{{missing}}"},
+			},
+		}, lookup)
+		var unresolved *UnresolvedVariablesError
+		require.ErrorAs(t, err, &unresolved)
+		assert.Equal(t, []string{"missing", "other"}, unresolved.Names)
+		assert.EqualError(t, err, "unresolved variables: missing, other")
+	})
+}
@@ -20,8 +20,10 @@ func CreateListener(filePath string) (net.Listener, error) {
 
 type DialerFunc func(ctx context.Context, network, addr string) (net.Conn, error)
 
+// CreateDialer creates dialer that connects to the named pipe regardless of the address. It honors the context.
 func CreateDialer(filePath string) DialerFunc {
-	return func(_ context.Context, _, _ string) (net.Conn, error) {
-		return net.Dial(unixProtocol, filePath)
+	return func(ctx context.Context, _, _ string) (net.Conn, error) {
+		var d net.Dialer
+		return d.DialContext(ctx, unixProtocol, filePath)
 	}
 }
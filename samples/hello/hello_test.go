@@ -2,6 +2,7 @@ package hello
 
 import (
 	"net/http"
+	"path"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -51,5 +52,36 @@ Error: Name has to be Hello, but got Double Belomor
 		assert.Equal(t, []string{
 			"Error: Name has to be Hello, but got Double Belomor",
 		}, steps[0].ResponseHandlerTestErrors())
+
+		results := steps[0].TestResults()
+		require.Len(t, results, 2)
+		assert.Equal(t, "Request executed successfully", results[0].Name)
+		assert.True(t, results[0].Passed)
+		assert.Equal(t, "Failed test", results[1].Name)
+		assert.False(t, results[1].Passed)
+		assert.Equal(t, "Name has to be Hello, but got Double Belomor", results[1].Message)
+		assert.Equal(t, "hello.http:14:22", results[1].Location())
 	})
 }
+
+// Runs all requests of the file as subtests against the test server.
+func TestHelloRun(t *testing.T) {
+	pipeName := t.Name()
+	ts := testserver.NewTestServer(pipeName, http.MethodGet, "/hello", Handler)
+	ts.Start()
+	t.Cleanup(ts.Stop)
+
+	r := gpc.Run(t, "hello_pass.http", gpc.WithDialer(pipes.CreateDialer(pipeName)))
+	assert.False(t, r.TestFailed())
+}
+
+func BenchmarkHello(b *testing.B) {
+	pipeName := path.Join(b.TempDir(), "hello")
+	ts := testserver.NewTestServer(pipeName, http.MethodGet, "/hello", Handler)
+	ts.Start()
+	b.Cleanup(ts.Stop)
+
+	for i := 0; i < b.N; i++ {
+		gpc.Run(b, "hello_pass.http", gpc.WithDialer(pipes.CreateDialer(pipeName)))
+	}
+}
@@ -44,6 +44,16 @@ func (t *TestServer) Stop() {
 	}
 }
 
+// NewHandlerTestServer creates a new http server on Unix pipes that serves requests with the handler.
+func NewHandlerTestServer(name string, handler http.Handler) *TestServer {
+	return &TestServer{
+		name: name,
+		s: http.Server{
+			Handler: handler,
+		},
+	}
+}
+
 // NewTestServer creates a new http server on Unix pipes that serves only one service.
 func NewTestServer(name string, verb string, url string, handler func(http.ResponseWriter, *http.Request)) *TestServer {
 	res := &TestServer{